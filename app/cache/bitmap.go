@@ -0,0 +1,404 @@
+package cache
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+)
+
+const (
+	maxBitOffset = 1<<32 - 1
+
+	BitUnitByte = "byte"
+	BitUnitBit  = "bit"
+
+	OverflowWrap = "wrap"
+	OverflowSat  = "sat"
+	OverflowFail = "fail"
+)
+
+// BitFieldOp is a single GET, SET or INCRBY subcommand of BITFIELD.
+type BitFieldOp struct {
+	Kind     string // get, set or incrby
+	Signed   bool
+	Bits     int
+	Offset   int
+	Value    int64
+	Overflow string
+}
+
+func (c *cache) stringValue(key string) (string, bool, error) {
+	v, ok := c.data[key]
+	if !ok {
+		if _, ok := c.listData[key]; ok {
+			return "", false, ErrWrongType
+		}
+		if _, ok := c.streamData[key]; ok {
+			return "", false, ErrWrongType
+		}
+		if _, ok := c.zsetData[key]; ok {
+			return "", false, ErrWrongType
+		}
+		return "", false, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", false, ErrWrongType
+	}
+
+	return s, true, nil
+}
+
+func (c *cache) SetBit(key string, offset int, bit int) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	s, _, err := c.stringValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := growBytes([]byte(s), offset/8+1)
+	old := getBit(buf, offset)
+	setBit(buf, offset, bit)
+
+	c.data[key] = string(buf)
+	c.touch(key)
+	return old, nil
+}
+
+func (c *cache) GetBit(key string, offset int) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	s, _, err := c.stringValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset/8 >= len(s) {
+		return 0, nil
+	}
+
+	return getBit([]byte(s), offset), nil
+}
+
+func (c *cache) BitCount(key string, start, end int, unit string) (int, error) {
+	s, _, err := c.stringValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	total := len(s)
+	if unit == BitUnitBit {
+		total *= 8
+	}
+
+	start, end, ok := normalizeBitRange(start, end, total)
+	if !ok {
+		return 0, nil
+	}
+
+	buf := []byte(s)
+	if unit != BitUnitBit {
+		return popCount(buf[start : end+1]), nil
+	}
+
+	var count int
+	for i := start; i <= end; i++ {
+		count += getBit(buf, i)
+	}
+	return count, nil
+}
+
+// BitPos returns the position of the first bit set to bit. When end is nil and
+// a clear bit is requested, the string is considered padded with zeros on the
+// right, so a fully set range reports the first bit past it.
+func (c *cache) BitPos(key string, bit int, start int, end *int, unit string) (int, error) {
+	s, exists, err := c.stringValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	total := len(s)
+	if unit == BitUnitBit {
+		total *= 8
+	}
+
+	last := total - 1
+	if end != nil {
+		last = *end
+	}
+
+	first, last, ok := normalizeBitRange(start, last, total)
+	if !ok {
+		return -1, nil
+	}
+
+	if unit != BitUnitBit {
+		first, last = first*8, last*8+7
+	}
+
+	buf := []byte(s)
+	for i := first; i <= last; i++ {
+		if getBit(buf, i) == bit {
+			return i, nil
+		}
+	}
+
+	if bit == 0 && end == nil {
+		return last + 1, nil
+	}
+	return -1, nil
+}
+
+func (c *cache) BitOp(op string, dest string, keys []string) (int, error) {
+	op = strings.ToLower(op)
+	if op == "not" && len(keys) != 1 {
+		return 0, ErrBitOpNot
+	}
+
+	values := make([][]byte, len(keys))
+	var maxLen int
+	for i, key := range keys {
+		s, _, err := c.stringValue(key)
+		if err != nil {
+			return 0, err
+		}
+
+		values[i] = []byte(s)
+		maxLen = max(maxLen, len(s))
+	}
+
+	res := make([]byte, maxLen)
+	for i := range res {
+		var b byte
+		for j, v := range values {
+			var cur byte
+			if i < len(v) {
+				cur = v[i]
+			}
+
+			switch {
+			case op == "not":
+				b = ^cur
+			case j == 0:
+				b = cur
+			case op == "and":
+				b &= cur
+			case op == "or":
+				b |= cur
+			case op == "xor":
+				b ^= cur
+			}
+		}
+		res[i] = b
+	}
+
+	if maxLen == 0 {
+		c.Del(dest)
+		return 0, nil
+	}
+
+	c.data[dest] = string(res)
+	c.touch(dest)
+	return maxLen, nil
+}
+
+// BitField applies ops in order and returns one reply per GET, SET and INCRBY.
+// A nil reply means the FAIL overflow policy prevented the operation.
+func (c *cache) BitField(key string, ops []BitFieldOp) ([]any, error) {
+	s, _, err := c.stringValue(key)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := []byte(s)
+	var written bool
+	res := make([]any, 0, len(ops))
+	for _, op := range ops {
+		if op.Kind != "get" {
+			buf = growBytes(buf, (op.Offset+op.Bits-1)/8+1)
+		}
+
+		old := readBitField(buf, op.Offset, op.Bits, op.Signed)
+
+		switch op.Kind {
+		case "get":
+			res = append(res, int(old))
+			continue
+		case "set":
+			v, overflow := bitFieldOverflow(op.Value, 0, op.Bits, op.Signed, op.Overflow)
+			if overflow && op.Overflow == OverflowFail {
+				res = append(res, nil)
+				continue
+			}
+
+			writeBitField(buf, op.Offset, op.Bits, uint64(v))
+			res = append(res, int(old))
+		case "incrby":
+			v, overflow := bitFieldOverflow(old, op.Value, op.Bits, op.Signed, op.Overflow)
+			if overflow && op.Overflow == OverflowFail {
+				res = append(res, nil)
+				continue
+			}
+
+			writeBitField(buf, op.Offset, op.Bits, uint64(v))
+			res = append(res, int(v))
+		}
+		written = true
+	}
+
+	if written {
+		c.data[key] = string(buf)
+		c.touch(key)
+	}
+	return res, nil
+}
+
+func normalizeBitRange(start, end, total int) (int, int, bool) {
+	if total == 0 {
+		return 0, 0, false
+	}
+
+	if start < 0 {
+		start = total + start
+	}
+	if end < 0 {
+		end = total + end
+	}
+	start = max(start, 0)
+	end = max(end, 0)
+	end = min(end, total-1)
+
+	if start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+func growBytes(buf []byte, n int) []byte {
+	if len(buf) >= n {
+		return buf
+	}
+	return append(buf, make([]byte, n-len(buf))...)
+}
+
+// getBit reads bits most significant first, so offset 0 is the high bit of the
+// first byte.
+func getBit(buf []byte, offset int) int {
+	idx := offset / 8
+	if idx >= len(buf) {
+		return 0
+	}
+	return int(buf[idx]>>(7-uint(offset%8))) & 1
+}
+
+func setBit(buf []byte, offset int, bit int) {
+	mask := byte(1) << (7 - uint(offset%8))
+	if bit == 1 {
+		buf[offset/8] |= mask
+	} else {
+		buf[offset/8] &^= mask
+	}
+}
+
+func popCount(buf []byte) int {
+	var count int
+	for _, b := range buf {
+		count += bits.OnesCount8(b)
+	}
+	return count
+}
+
+func readBitField(buf []byte, offset, width int, signed bool) int64 {
+	var v uint64
+	for i := 0; i < width; i++ {
+		v = v<<1 | uint64(getBit(buf, offset+i))
+	}
+
+	if signed && width < 64 && v&(1<<(width-1)) != 0 {
+		v |= math.MaxUint64 << width
+	}
+	return int64(v)
+}
+
+func writeBitField(buf []byte, offset, width int, v uint64) {
+	for i := 0; i < width; i++ {
+		setBit(buf, offset+i, int(v>>(width-1-i))&1)
+	}
+}
+
+// bitFieldOverflow computes value+incr for a field of the given width and
+// reports whether it overflowed. The returned value already has the policy
+// applied: wrapped for WRAP, clamped for SAT.
+func bitFieldOverflow(value, incr int64, width int, signed bool, policy string) (int64, bool) {
+	if !signed {
+		limit := uint64(math.MaxUint64)
+		if width < 64 {
+			limit = 1<<width - 1
+		}
+
+		u := uint64(value)
+		maxIncr := int64(limit - u)
+		minIncr := -value
+
+		switch {
+		case u > limit || (incr > 0 && incr > maxIncr):
+			if policy == OverflowSat {
+				return int64(limit), true
+			}
+		case incr < 0 && incr < minIncr:
+			if policy == OverflowSat {
+				return 0, true
+			}
+		default:
+			return value + incr, false
+		}
+
+		return int64((u + uint64(incr)) &^ (math.MaxUint64 << width)), true
+	}
+
+	limit := int64(math.MaxInt64)
+	if width < 64 {
+		limit = 1<<(width-1) - 1
+	}
+	floor := -limit - 1
+
+	maxIncr := limit - value
+	minIncr := floor - value
+
+	switch {
+	case value > limit || (width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		if policy == OverflowSat {
+			return limit, true
+		}
+	case value < floor || (width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		if policy == OverflowSat {
+			return floor, true
+		}
+	default:
+		return value + incr, false
+	}
+
+	res := uint64(value) + uint64(incr)
+	if width < 64 {
+		mask := uint64(math.MaxUint64) << width
+		if res&(1<<(width-1)) != 0 {
+			res |= mask
+		} else {
+			res &^= mask
+		}
+	}
+	return int64(res), true
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetBitUsesBigEndianBitOrder(t *testing.T) {
+	c := New()
+
+	old, err := c.SetBit("bm", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, old)
+
+	val, _ := c.Get("bm")
+	assert.Equal(t, "\x40", val)
+
+	bit, err := c.GetBit("bm", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, bit)
+
+	bit, err = c.GetBit("bm", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 0, bit)
+}
+
+func TestBitCountByteAndBitRanges(t *testing.T) {
+	c := New()
+	c.Set("s", "foobar")
+
+	n, err := c.BitCount("s", 0, -1, BitUnitByte)
+	require.NoError(t, err)
+	assert.Equal(t, 26, n)
+
+	n, _ = c.BitCount("s", 1, 1, BitUnitByte)
+	assert.Equal(t, 6, n)
+
+	n, _ = c.BitCount("s", 5, 30, BitUnitBit)
+	assert.Equal(t, 17, n)
+}
+
+func TestBitPosHonoursExplicitEnd(t *testing.T) {
+	c := New()
+	c.Set("s", "\xff\xf0\x00")
+
+	pos, _ := c.BitPos("s", 0, 0, nil, BitUnitByte)
+	assert.Equal(t, 12, pos)
+
+	c.Set("full", "\xff\xff")
+	pos, _ = c.BitPos("full", 0, 0, nil, BitUnitByte)
+	assert.Equal(t, 16, pos)
+
+	end := -1
+	pos, _ = c.BitPos("full", 0, 0, &end, BitUnitByte)
+	assert.Equal(t, -1, pos)
+
+	pos, _ = c.BitPos("missing", 1, 0, nil, BitUnitByte)
+	assert.Equal(t, -1, pos)
+}
+
+func TestBitOpPadsShorterKeys(t *testing.T) {
+	c := New()
+	c.Set("a", "\xff\x0f")
+	c.Set("b", "\x0f")
+
+	n, err := c.BitOp("and", "dest", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	val, _ := c.Get("dest")
+	assert.Equal(t, "\x0f\x00", val)
+
+	_, err = c.BitOp("not", "dest", []string{"a", "b"})
+	assert.ErrorIs(t, err, ErrBitOpNot)
+}
+
+func TestBitFieldOverflowPolicies(t *testing.T) {
+	c := New()
+
+	r, err := c.BitField("bf", []BitFieldOp{
+		{Kind: "incrby", Bits: 2, Offset: 100, Value: 1, Overflow: OverflowWrap},
+		{Kind: "incrby", Bits: 2, Offset: 100, Value: 3, Overflow: OverflowWrap},
+		{Kind: "incrby", Signed: true, Bits: 8, Offset: 0, Value: 200, Overflow: OverflowSat},
+		{Kind: "incrby", Bits: 4, Offset: 8, Value: 20, Overflow: OverflowFail},
+		{Kind: "set", Signed: true, Bits: 8, Offset: 16, Value: -3, Overflow: OverflowWrap},
+		{Kind: "get", Bits: 8, Offset: 16},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{1, 0, 127, nil, 0, 253}, r)
+}
+
+func TestBitCommandsRejectWrongType(t *testing.T) {
+	c := New()
+	c.RPush("list", []any{"a"})
+
+	_, err := c.SetBit("list", 0, 1)
+	assert.ErrorIs(t, err, ErrWrongType)
+}
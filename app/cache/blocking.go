@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// listWaiter is a client blocked on a list. Whoever pushes to the list pops
+// its head on the waiter's behalf, so that the pop happens under the same
+// lock as the push.
+type listWaiter struct {
+	key string
+	res chan any
+}
+
+// BLPop pops the head of the list at key, waiting up to timeout seconds for
+// an element to be pushed if it is empty; a zero timeout waits forever. The
+// channel receives the key and the element, or nil on timeout. cancel gives up
+// waiting, for a client that goes away.
+func (c *cache) BLPop(key string, timeout float64) (res chan any, cancel func()) {
+	res = make(chan any, 1)
+	if v := c.LPop(key, nil); v != nil {
+		res <- []any{key, v}
+		return res, func() {}
+	}
+
+	w := &listWaiter{key: key, res: res}
+
+	c.waitMu.Lock()
+	c.listWaiters[key] = append(c.listWaiters[key], w)
+	c.waitMu.Unlock()
+
+	if timeout > 0 {
+		time.AfterFunc(time.Duration(timeout*float64(time.Second)), func() {
+			c.waitMu.Lock()
+			defer c.waitMu.Unlock()
+
+			if c.removeListWaiter(w) {
+				res <- nil
+			}
+		})
+	}
+
+	return res, func() {
+		c.waitMu.Lock()
+		defer c.waitMu.Unlock()
+
+		c.removeListWaiter(w)
+	}
+}
+
+// signalListWaiters hands the elements pushed to key over to the clients
+// blocked on it, oldest first.
+func (c *cache) signalListWaiters(key string) {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+
+	for len(c.listWaiters[key]) > 0 {
+		v := c.LPop(key, nil)
+		if v == nil {
+			return
+		}
+
+		w := c.listWaiters[key][0]
+		c.removeListWaiter(w)
+		w.res <- []any{key, v}
+	}
+}
+
+// removeListWaiter unregisters w and reports whether it was still
+// registered. waitMu must be held.
+func (c *cache) removeListWaiter(w *listWaiter) bool {
+	waiters := c.listWaiters[w.key]
+	idx := slices.Index(waiters, w)
+	if idx < 0 {
+		return false
+	}
+
+	waiters = slices.Delete(waiters, idx, idx+1)
+	if len(waiters) == 0 {
+		delete(c.listWaiters, w.key)
+	} else {
+		c.listWaiters[w.key] = waiters
+	}
+	return true
+}
+
+// streamWaiter is a client blocked on one or more streams. Whoever appends to
+// one of its keys runs read on the waiter's behalf and hands over the result,
+// so a blocked read is served by the writer rather than by polling.
+type streamWaiter struct {
+	keys []string
+	read func() []any
+	res  chan []any
+}
+
+// XReadBlock is XRead that waits up to timeout for entries when none are
+// available yet; a zero timeout waits forever. A "$" id stands for the last
+// id in the stream at the time of the call. The channel receives nil on
+// timeout; cancel gives up waiting.
+func (c *cache) XReadBlock(keys []string, targetIDs []string, count int, timeout time.Duration) (res chan []any, cancel func()) {
+	ids := make([]string, len(targetIDs))
+	for i, id := range targetIDs {
+		if id == "$" {
+			id = c.streamLastID(keys[i])
+		}
+		ids[i] = id
+	}
+
+	return c.blockOnStreams(keys, timeout, func() []any {
+		return c.XRead(keys, ids, count)
+	})
+}
+
+// XReadGroupBlock is XReadGroup that waits up to timeout for new entries.
+// Reads of a consumer's history never block.
+func (c *cache) XReadGroupBlock(group, name string, keys, ids []string, count int, noAck bool, timeout time.Duration) (res chan []any, cancel func(), err error) {
+	r, err := c.XReadGroup(group, name, keys, ids, count, noAck)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r != nil {
+		res = make(chan []any, 1)
+		res <- r
+		return res, func() {}, nil
+	}
+
+	res, cancel = c.blockOnStreams(keys, timeout, func() []any {
+		r, _ := c.XReadGroup(group, name, keys, ids, count, noAck)
+		return r
+	})
+	return res, cancel, nil
+}
+
+func (c *cache) blockOnStreams(keys []string, timeout time.Duration, read func() []any) (chan []any, func()) {
+	res := make(chan []any, 1)
+	if r := read(); r != nil {
+		res <- r
+		return res, func() {}
+	}
+
+	w := &streamWaiter{keys: keys, read: read, res: res}
+
+	c.waitMu.Lock()
+	for _, key := range keys {
+		c.streamWaiters[key] = append(c.streamWaiters[key], w)
+	}
+	c.waitMu.Unlock()
+
+	if timeout > 0 {
+		time.AfterFunc(timeout, func() {
+			c.waitMu.Lock()
+			defer c.waitMu.Unlock()
+
+			if c.removeStreamWaiter(w) {
+				res <- nil
+			}
+		})
+	}
+
+	return res, func() {
+		c.waitMu.Lock()
+		defer c.waitMu.Unlock()
+
+		c.removeStreamWaiter(w)
+	}
+}
+
+// signalStreamWaiters serves clients blocked on key, oldest first, after an
+// append to it.
+func (c *cache) signalStreamWaiters(key string) {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+
+	for _, w := range slices.Clone(c.streamWaiters[key]) {
+		r := w.read()
+		if r == nil {
+			continue
+		}
+
+		c.removeStreamWaiter(w)
+		w.res <- r
+	}
+}
+
+// removeStreamWaiter unregisters w from all its keys and reports whether it
+// was still registered. waitMu must be held.
+func (c *cache) removeStreamWaiter(w *streamWaiter) bool {
+	var found bool
+	for _, key := range w.keys {
+		waiters := c.streamWaiters[key]
+		idx := slices.Index(waiters, w)
+		if idx < 0 {
+			continue
+		}
+
+		found = true
+		waiters = slices.Delete(waiters, idx, idx+1)
+		if len(waiters) == 0 {
+			delete(c.streamWaiters, key)
+		} else {
+			c.streamWaiters[key] = waiters
+		}
+	}
+	return found
+}
+
+// signalAllWaiters serves the clients blocked on any key that has data, for
+// when the keys change all at once.
+func (c *cache) signalAllWaiters() {
+	c.waitMu.Lock()
+	lists := slices.Collect(maps.Keys(c.listWaiters))
+	streams := slices.Collect(maps.Keys(c.streamWaiters))
+	c.waitMu.Unlock()
+
+	for _, key := range lists {
+		c.signalListWaiters(key)
+	}
+	for _, key := range streams {
+		c.signalStreamWaiters(key)
+	}
+}
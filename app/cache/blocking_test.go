@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXReadBlockIsWokenByXAdd(t *testing.T) {
+	c := New()
+	c.XAdd("s", "1-1", []any{"a", "1"})
+
+	wait, _ := c.XReadBlock([]string{"other", "s"}, []string{"$", "$"}, 0, 0)
+	select {
+	case <-wait:
+		t.Fatal("XReadBlock returned before any new entry")
+	default:
+	}
+
+	c.XAdd("s", "2-1", []any{"b", "2"})
+
+	select {
+	case r := <-wait:
+		assert.Equal(t, []any{[]any{"s", []any{[2]any{"2-1", []any{"b", "2"}}}}}, r)
+	case <-time.After(time.Second):
+		t.Fatal("XReadBlock was not woken by XAdd")
+	}
+}
+
+func TestXReadBlockTimesOutWithNil(t *testing.T) {
+	c := New()
+
+	wait, _ := c.XReadBlock([]string{"s"}, []string{"$"}, 0, 10*time.Millisecond)
+	select {
+	case r := <-wait:
+		assert.Nil(t, r)
+	case <-time.After(time.Second):
+		t.Fatal("XReadBlock did not time out")
+	}
+
+	c.XAdd("s", "1-1", []any{"a", "1"})
+	assert.Empty(t, c.(*cache).streamWaiters)
+}
+
+func TestXReadGroupBlockReturnsPendingImmediately(t *testing.T) {
+	c := New()
+	c.XAdd("s", "1-1", []any{"a", "1"})
+	require.NoError(t, c.XGroupCreate("s", "g", "0", false, nil))
+
+	wait, _, err := c.XReadGroupBlock("g", "c", []string{"s"}, []string{">"}, 0, false, time.Second)
+	require.NoError(t, err)
+	assert.Len(t, <-wait, 1)
+
+	_, _, err = c.XReadGroupBlock("missing", "c", []string{"s"}, []string{">"}, 0, false, time.Second)
+	assert.Error(t, err)
+}
+
+func TestBLPopIsServedByPush(t *testing.T) {
+	c := New()
+
+	first, _ := c.BLPop("l", 0)
+	second, _ := c.BLPop("l", 0)
+	select {
+	case <-first:
+		t.Fatal("BLPop returned before any push")
+	default:
+	}
+
+	// The push pops for the oldest waiter before it returns.
+	assert.Equal(t, 1, c.RPush("l", []any{"a"}))
+	assert.Equal(t, []any{"l", "a"}, <-first)
+	assert.Equal(t, 0, c.LLen("l"))
+
+	c.LPush("l", []any{"b"})
+	assert.Equal(t, []any{"l", "b"}, <-second)
+	assert.Empty(t, c.(*cache).listWaiters)
+}
+
+func TestSwapServesWaiters(t *testing.T) {
+	a, b := New(), New()
+	list, _ := a.BLPop("l", 0)
+	stream, _ := a.XReadBlock([]string{"s"}, []string{"$"}, 0, 0)
+
+	b.RPush("l", []any{"x"})
+	b.XAdd("s", "1-1", []any{"f", "v"})
+	a.Swap(b)
+
+	assert.Equal(t, []any{"l", "x"}, <-list)
+	assert.Equal(t, []any{[]any{"s", []any{[2]any{"1-1", []any{"f", "v"}}}}}, <-stream)
+	assert.Empty(t, a.(*cache).listWaiters)
+	assert.Empty(t, a.(*cache).streamWaiters)
+	assert.Equal(t, 0, a.LLen("l"))
+	assert.False(t, b.Exists("l"))
+}
+
+func TestBLPopTimesOut(t *testing.T) {
+	c := New()
+
+	wait, _ := c.BLPop("l", 0.01)
+	select {
+	case r := <-wait:
+		assert.Nil(t, r)
+	case <-time.After(time.Second):
+		t.Fatal("BLPop did not time out")
+	}
+
+	c.RPush("l", []any{"a"})
+	assert.Equal(t, 1, c.LLen("l"))
+}
+
+func TestCancelledWaitersAreUnregistered(t *testing.T) {
+	c := New()
+
+	_, cancel := c.XReadBlock([]string{"s"}, []string{"$"}, 0, 0)
+	cancel()
+	assert.Empty(t, c.(*cache).streamWaiters)
+
+	_, cancel = c.BLPop("l", 0)
+	cancel()
+	c.RPush("l", []any{"a"})
+	assert.Equal(t, 1, c.LLen("l"), "the element is left for others")
+}
+
+func TestXReadDollarWithoutBlockReturnsNothing(t *testing.T) {
+	c := New()
+	c.XAdd("s", "1-1", []any{"a", "1"})
+
+	assert.Nil(t, c.XRead([]string{"s"}, []string{"$"}, 0))
+}
@@ -1,20 +1,37 @@
 package cache
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"golang.org/x/sync/errgroup"
 )
 
 var (
 	_ Cache = (*cache)(nil)
 )
 
+var (
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+	ErrBitOpNot  = errors.New("ERR BITOP NOT must be called with a single source key.")
+
+	ErrInvalidHLL = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	ErrGeoMember  = errors.New("ERR could not decode requested zset member")
+
+	ErrInvalidStreamID  = errors.New("ERR Invalid stream ID specified as stream command argument")
+	ErrStreamIDZero     = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	ErrStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrBusyGroup        = errors.New("BUSYGROUP Consumer Group name already exists")
+	ErrNoSuchKey        = errors.New("ERR no such key")
+	ErrIntervalStart    = errors.New("ERR invalid start ID for the interval")
+	ErrIntervalEnd      = errors.New("ERR invalid end ID for the interval")
+	ErrXGroupKey        = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
 type Cache interface {
 	Set(key string, value any)
 	Get(key string) (any, bool)
@@ -26,40 +43,110 @@ type Cache interface {
 	RPop(key string, count *int) any
 	LPop(key string, count *int) any
 	Type(key string) string
-	BLPop(key string, timeout float64) chan any
-	XAdd(key string, id string, elems []any) (string, bool)
-	XRange(key string, start string, end string) []any
-	XRead(kind string, keys []string, targetIDs []string) []any
+	BLPop(key string, timeout float64) (chan any, func())
+	XAdd(key string, id string, elems []any) (string, error)
+	XRange(key string, start string, end string, count int) ([]any, error)
+	XRevRange(key string, end string, start string, count int) ([]any, error)
+	XLen(key string) int
+	XDel(key string, ids []string) (int, error)
+	XTrim(key string, opts XTrimOptions) (int, error)
+	XInfoStream(key string) ([]any, error)
+	XInfoGroups(key string) ([]any, error)
+	XInfoConsumers(key, group string) ([]any, error)
+	XRead(keys []string, targetIDs []string, count int) []any
+	XReadBlock(keys []string, targetIDs []string, count int, timeout time.Duration) (chan []any, func())
+	SetBit(key string, offset int, bit int) (int, error)
+	GetBit(key string, offset int) (int, error)
+	BitCount(key string, start, end int, unit string) (int, error)
+	BitPos(key string, bit int, start int, end *int, unit string) (int, error)
+	BitOp(op string, dest string, keys []string) (int, error)
+	BitField(key string, ops []BitFieldOp) ([]any, error)
+	PFAdd(key string, elems []string) (int, error)
+	PFCount(keys []string) (int, error)
+	PFMerge(dest string, keys []string) error
+	GeoAdd(key string, members []GeoMember, mode string, ch bool) (int, error)
+	GeoPos(key string, members []string) ([]any, error)
+	GeoDist(key, from, to string) (float64, bool, error)
+	GeoHash(key string, members []string) ([]any, error)
+	GeoSearch(key string, q GeoQuery) ([]GeoResult, error)
+	GeoSearchStore(dest, key string, q GeoQuery, storeDist bool, unit float64) (int, error)
+	XGroupCreate(key, group, id string, mkStream bool, entriesRead *int) error
+	XGroupSetID(key, group, id string, entriesRead *int) error
+	XGroupDestroy(key, group string) (int, error)
+	XGroupCreateConsumer(key, group, name string) (int, error)
+	XGroupDelConsumer(key, group, name string) (int, error)
+	XReadGroup(group, name string, keys, ids []string, count int, noAck bool) ([]any, error)
+	XReadGroupBlock(group, name string, keys, ids []string, count int, noAck bool, timeout time.Duration) (chan []any, func(), error)
+	XAck(key, group string, ids []string) (int, error)
+	XPending(key, group string) ([]any, error)
+	XPendingRange(key, group string, minIdle time.Duration, start, end string, count int, name string) ([]any, error)
+	XClaim(key, group, name string, minIdle time.Duration, ids []string, opts XClaimOptions) ([]any, error)
+	XAutoClaim(key, group, name string, minIdle time.Duration, start string, count int, justID bool) ([]any, error)
+
+	Watch(key string) uint64
+	Unwatch(key string)
+	KeyVersion(key string) uint64
+
+	Exists(key string) bool
+	Len() int
+	Flush()
+	Move(key string, dst Cache) bool
+	Swap(other Cache)
+	Remove(key string) bool
+	Expire(key string, at time.Time)
+	ExpiresAt(key string) (time.Time, bool)
+	ExpiringKeys() (int, time.Duration)
+
+	Encoding(key string) (string, bool)
+	UsedMemory() int
+	MemoryUsage(key string, samples int) (int, bool)
+	Access(key string)
+	Stats(key string) (KeyStats, bool)
+	Sample(n int, volatile bool) []KeyStats
 }
 type cache struct {
-	data               map[any]any
-	listData           map[any][]any
-	blockedClients     []chan any
-	listDataInsertChan chan struct{}
-	streamData         map[any][][2]any
+	data          map[any]any
+	listData      map[any][]any
+	streamData    map[any]*stream
+	zsetData      map[any]*sortedSet
+	listWaiters   map[string][]*listWaiter
+	streamWaiters map[string][]*streamWaiter
+	waitMu        sync.Mutex
+	watched       map[string]*watchedKey
+	version       uint64
+
+	// expires holds the expiry of keys that have one.
+	expires map[string]time.Time
+	// meta holds the size and access statistics of every key. Keys written
+	// since they were last measured are in dirty, and used is the sum of the
+	// sizes.
+	meta  map[string]*keyMeta
+	dirty map[string]struct{}
+	used  int
 }
 
 func New() Cache {
 	c := &cache{
-		data:               make(map[any]any),
-		listData:           make(map[any][]any),
-		blockedClients:     []chan any{},
-		listDataInsertChan: make(chan struct{}, 3),
-		streamData:         make(map[any][][2]any),
+		data:          make(map[any]any),
+		listData:      make(map[any][]any),
+		streamData:    make(map[any]*stream),
+		zsetData:      make(map[any]*sortedSet),
+		listWaiters:   make(map[string][]*listWaiter),
+		streamWaiters: make(map[string][]*streamWaiter),
+		watched:       make(map[string]*watchedKey),
+		expires:       make(map[string]time.Time),
+		meta:          make(map[string]*keyMeta),
+		dirty:         make(map[string]struct{}),
 	}
 
-	go c.runJob()
 	return c
 }
 
-func (c *cache) runJob() {
-	for {
-		select {}
-	}
-}
-
+// Set stores a string value, clearing any expiry of key.
 func (c *cache) Set(key string, value any) {
 	c.data[key] = value
+	delete(c.expires, key)
+	c.touch(key)
 }
 
 func (c *cache) Get(key string) (any, bool) {
@@ -68,29 +155,33 @@ func (c *cache) Get(key string) (any, bool) {
 }
 
 func (c *cache) Del(key string) any {
-	old := c.data[key]
-	delete(c.data, key)
+	old, ok := c.data[key]
+	if ok {
+		delete(c.data, key)
+		delete(c.expires, key)
+		c.touch(key)
+	}
 	return old
 }
 
 func (c *cache) RPush(key string, data []any) int {
 	v, _ := c.listData[key]
 	c.listData[key] = append(v, data...)
-	if len(c.blockedClients) > 0 { // spread event
-		c.listDataInsertChan <- struct{}{}
-	}
+	c.touch(key)
 
-	return len(v) + len(data)
+	n := len(v) + len(data)
+	c.signalListWaiters(key)
+	return n
 }
 
 func (c *cache) LPush(key string, data []any) int {
 	v, _ := c.listData[key]
 	c.listData[key] = append(data, v...)
-	if len(c.blockedClients) > 0 { // spread event
-		c.listDataInsertChan <- struct{}{}
-	}
+	c.touch(key)
 
-	return len(v) + len(data)
+	n := len(v) + len(data)
+	c.signalListWaiters(key)
+	return n
 }
 
 func (c *cache) LRange(key string, start, end int) []any {
@@ -138,6 +229,7 @@ func (c *cache) RPop(key string, count *int) any {
 	if len(v) == 0 {
 		return nil
 	}
+	c.touch(key)
 
 	if count == nil { // default
 		r := v[len(v)-1]
@@ -166,6 +258,7 @@ func (c *cache) LPop(key string, count *int) any {
 	if len(v) == 0 {
 		return nil
 	}
+	c.touch(key)
 
 	if count == nil { // default
 		r := v[0]
@@ -195,7 +288,10 @@ func (c *cache) Type(key string) string {
 		if !ok {
 			v, ok = c.streamData[key]
 			if !ok {
-				return "none"
+				v, ok = c.zsetData[key]
+				if !ok {
+					return "none"
+				}
 			}
 		}
 	}
@@ -213,220 +309,183 @@ func (c *cache) Type(key string) string {
 		return "array"
 	case map[any]any:
 		return "map"
-	case [][2]any:
+	case *stream:
 		return "stream"
+	case *sortedSet:
+		return "zset"
 	default:
 		return "none"
 	}
 }
-func (c *cache) BLPop(key string, timeout float64) chan any {
-	commChan := make(chan any)
-	c.blockedClients = append(c.blockedClients, commChan)
-	go func() {
-		v, ok := c.listData[key]
-		if ok || len(v) > 0 {
-			c.listData[key] = v[1:]
-			commChan <- []any{key, v[0]}
-			return
-		}
-
-		ctx := context.Background()
-		if timeout > 0 {
-			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout*float64(time.Second)))
-			defer cancel()
-		}
-
-		for {
-			select {
-			case <-ctx.Done():
-				commChan <- ""
-				return
-			case <-c.listDataInsertChan:
-				firstChan := c.blockedClients[0]
-				v, ok := c.listData[key]
-				if ok || len(v) > 0 {
-					c.listData[key] = v[1:]
-					firstChan <- []any{key, v[0]}
-					return
-				}
-			}
-		}
-	}()
-	return commChan
-}
 
-func (c *cache) XAdd(key string, id string, elems []any) (string, bool) {
-	m, ok := c.streamData[key]
+// XAdd appends an entry to the stream at key, creating it if needed. id may
+// be explicit, "ms-*" to pick the next sequence for ms, or "*" to derive the
+// whole id from the clock; it must be greater than every id the stream ever
+// had.
+func (c *cache) XAdd(key string, id string, elems []any) (string, error) {
+	s, ok := c.streamData[key]
 	if !ok {
-		m = make([][2]any, 0)
+		s = newStream()
 	}
 
-	var valid bool
-	id, valid = validateXAddID(id, m)
-	if !valid {
-		return id, false
+	newID, err := nextStreamID(id, s.lastID)
+	if err != nil {
+		return "", err
 	}
 
-	d := [2]any{id, elems}
-	m = append(m, d)
+	c.streamData[key] = s
+	s.append(newID, elems)
+	c.touch(key)
 
-	c.streamData[key] = m
-	return id, true
+	c.signalStreamWaiters(key)
+	return newID.String(), nil
 }
 
-func validateXAddID(id string, data [][2]any) (string, bool) {
-
+// nextStreamID resolves the auto generated parts of id and checks that it is
+// greater than lastID.
+func nextStreamID(id string, lastID StreamID) (StreamID, error) {
 	if id == "*" {
-		id = fmt.Sprintf("%d-*", time.Now().UnixMilli())
-	}
-
-	if len(data) == 0 {
-		if strings.HasSuffix(id, "-*") {
-			firstSplit := strings.Split(id, "-")[0]
-			id = "0-1"
-			if firstSplit > "0" {
-				id = firstSplit + "-0"
-			}
+		ms := max(uint64(time.Now().UnixMilli()), lastID.Ms)
+		if ms > lastID.Ms {
+			return StreamID{Ms: ms}, nil
 		}
 
-		return id, true
+		next, ok := lastID.next()
+		if !ok {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return next, nil
 	}
 
-	last := data[len(data)-1]
-
-	idSplit := strings.Split(id, "-")
-	if len(idSplit) != 2 {
-		return id, false
-	}
+	if msPart, ok := strings.CutSuffix(id, "-*"); ok {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return StreamID{}, ErrInvalidStreamID
+		}
 
-	newTime, newIncr := idSplit[0], idSplit[1]
-	lastSplit := strings.Split(last[0].(string), "-")
-	if len(lastSplit) != 2 {
-		return id, false
+		switch {
+		case ms < lastID.Ms:
+			return StreamID{}, ErrStreamIDTooSmall
+		case ms > lastID.Ms:
+			return StreamID{Ms: ms}, nil
+		case lastID.Seq == math.MaxUint64:
+			return StreamID{}, ErrStreamIDTooSmall
+		default:
+			return StreamID{Ms: ms, Seq: lastID.Seq + 1}, nil
+		}
 	}
 
-	lastTime, lastIncr := lastSplit[0], lastSplit[1]
-
+	newID, ok := parseStreamID(id, 0)
 	switch {
-	case newTime < lastTime:
-		return id, false
-	case newIncr == "*":
-		toAdd := "0"
-		if newTime == lastTime {
-			lastIncr, _ := strconv.Atoi(lastIncr)
-			toAdd = strconv.Itoa(lastIncr + 1)
-		}
+	case !ok:
+		return StreamID{}, ErrInvalidStreamID
+	case newID.IsZero():
+		return StreamID{}, ErrStreamIDZero
+	case newID.Compare(lastID) <= 0:
+		return StreamID{}, ErrStreamIDTooSmall
+	}
+	return newID, nil
+}
 
-		id = newTime + "-" + toAdd
-		return id, true
-	case newTime == lastTime && newIncr <= lastIncr:
-		return "", false
-	default:
-		return id, true
+// XRange returns the entries with an id between start and end inclusive,
+// which may be "-" and "+", or ids prefixed with "(" to exclude them. An end
+// without a sequence covers the whole millisecond.
+func (c *cache) XRange(key string, start string, end string, count int) ([]any, error) {
+	from, to, err := parseStreamRange(start, end)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (c *cache) XRange(key string, start string, end string) []any {
-	x, ok := c.streamData[key]
+	res := []any{}
+	s, ok := c.streamData[key]
 	if !ok {
-		return nil
+		return res, nil
 	}
 
-	start, end = validateXRangeFilters(start, end)
-
-	var res []any
-	for _, v := range x {
-		id := v[0].(string)
-		if idISGreaterOrEqual(id, start) && idIsLessOrEqual(id, end) {
-			res = append(res, v)
+	for e := range s.ascend(from) {
+		if e.id.Compare(to) > 0 || count > 0 && len(res) == count {
+			break
 		}
+		res = append(res, e.reply())
 	}
-	return res
+	return res, nil
 }
 
-func validateXRangeFilters(start string, end string) (string, string) {
-	if start == "-" {
-		start = "0-1"
-	} else if len(strings.Split(start, "-")) == 1 {
-		start = start + "-0"
+// XRevRange is XRange walking the stream from end back to start.
+func (c *cache) XRevRange(key string, end string, start string, count int) ([]any, error) {
+	from, to, err := parseStreamRange(start, end)
+	if err != nil {
+		return nil, err
 	}
 
-	if end == "+" {
-		end = fmt.Sprintf("%d-*", math.MaxInt64)
-	} else if len(strings.Split(end, "-")) == 1 {
-		end = end + "-0"
+	res := []any{}
+	s, ok := c.streamData[key]
+	if !ok {
+		return res, nil
 	}
 
-	return start, end
+	for e := range s.descend(to) {
+		if e.id.Compare(from) < 0 || count > 0 && len(res) == count {
+			break
+		}
+		res = append(res, e.reply())
+	}
+	return res, nil
 }
 
-func idISGreaterOrEqual(main string, target string) bool {
-	timeMain, incrMain := strings.Split(main, "-")[0], strings.Split(main, "-")[1]
-	timeTarget, incrTarget := strings.Split(target, "-")[0], strings.Split(target, "-")[1]
-	if timeMain > timeTarget {
-		return true
-	} else if timeMain == timeTarget {
-		incrMain, _ := strconv.Atoi(incrMain)
-		incrTarget, _ := strconv.Atoi(incrTarget)
-		return incrMain >= incrTarget
-	}
-	return false
-}
+func parseStreamRange(start, end string) (StreamID, StreamID, error) {
+	from, exclusive, ok := parseRangeBound(start, 0)
+	if !ok {
+		return StreamID{}, StreamID{}, ErrInvalidStreamID
+	}
+	if exclusive {
+		if from, ok = from.next(); !ok {
+			return StreamID{}, StreamID{}, ErrIntervalStart
+		}
+	}
 
-func idIsLessOrEqual(main string, target string) bool {
-	timeMain, incrMain := strings.Split(main, "-")[0], strings.Split(main, "-")[1]
-	timeTarget, incrTarget := strings.Split(target, "-")[0], strings.Split(target, "-")[1]
-	if timeMain < timeTarget {
-		return true
-	} else if timeMain == timeTarget {
-		incrMain, _ := strconv.Atoi(incrMain)
-		incrTarget, _ := strconv.Atoi(incrTarget)
-		return incrMain <= incrTarget
-	}
-	return false
+	to, exclusive, ok := parseRangeBound(end, math.MaxUint64)
+	if !ok {
+		return StreamID{}, StreamID{}, ErrInvalidStreamID
+	}
+	if exclusive {
+		if to, ok = to.prev(); !ok {
+			return StreamID{}, StreamID{}, ErrIntervalEnd
+		}
+	}
+	return from, to, nil
 }
 
-func idIsGreater(main string, target string) bool {
-	timeMain, incrMain := strings.Split(main, "-")[0], strings.Split(main, "-")[1]
-	timeTarget, incrTarget := strings.Split(target, "-")[0], strings.Split(target, "-")[1]
-	if timeMain > timeTarget {
-		return true
-	} else if timeMain == timeTarget {
-		incrMain, _ := strconv.Atoi(incrMain)
-		incrTarget, _ := strconv.Atoi(incrTarget)
-		return incrMain > incrTarget
-	}
-	return false
-}
+// XRead returns, per key, the entries with an id greater than the matching
+// target id. Keys without new entries are left out, and nil is returned when
+// no key has any.
+func (c *cache) XRead(keys []string, targetIDs []string, count int) []any {
+	var res []any
+	for i, key := range keys {
+		s, ok := c.streamData[key]
+		if !ok {
+			continue
+		}
 
-func (c *cache) XRead(kind string, keys []string, targetIDs []string) []any {
-	var gres []any
-	order := map[string]any{}
+		// "$" asks for entries added from now on, which a read that does
+		// not block cannot have.
+		if targetIDs[i] == "$" {
+			continue
+		}
+		after, _ := parseStreamID(targetIDs[i], 0)
 
-	var eg errgroup.Group
-	for i, key := range keys {
-		eg.Go(func() error {
-			var res []any
-			targetID := targetIDs[i]
-			v, _ := c.streamData[key]
-			for _, v := range v {
-				id := v[0].(string)
-				if idIsGreater(id, targetID) {
-					res = append(res, v)
-				}
+		var entries []any
+		for e := range s.ascendAfter(after) {
+			entries = append(entries, e.reply())
+			if count > 0 && len(entries) == count {
+				break
 			}
+		}
 
-			res = append([]any{key}, res)
-			order[key] = res
-			return nil
-		})
-	}
-	_ = eg.Wait()
-
-	for _, key := range keys {
-		gres = append(gres, order[key])
+		if len(entries) > 0 {
+			res = append(res, []any{key, entries})
+		}
 	}
 
-	return gres
+	return res
 }
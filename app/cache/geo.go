@@ -0,0 +1,458 @@
+package cache
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Geo members live in a sorted set scored by a 52-bit geohash: 26 bits of
+// latitude interleaved with 26 bits of longitude, longitude taking the odd
+// bits, exactly as Redis stores them.
+const (
+	GeoLatMin = -85.05112878
+	GeoLatMax = 85.05112878
+	GeoLonMin = -180.0
+	GeoLonMax = 180.0
+
+	geoStep        = 26
+	earthRadius    = 6372797.560856
+	geoHashChars   = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geoHashStrSize = 11
+)
+
+type GeoMember struct {
+	Name string
+	Lon  float64
+	Lat  float64
+}
+
+// GeoQuery describes a GEOSEARCH. Distances are in meters. When Member is set
+// the search is centred on it instead of Lon/Lat; when ByBox is set
+// Width/Height are used instead of Radius.
+type GeoQuery struct {
+	Member string
+	Lon    float64
+	Lat    float64
+	Radius float64
+	ByBox  bool
+	Width  float64
+	Height float64
+	Sort   string // "", "asc" or "desc"
+	Count  int
+	Any    bool
+}
+
+type GeoResult struct {
+	Name string
+	Dist float64
+	Hash uint64
+	Lon  float64
+	Lat  float64
+}
+
+func (c *cache) zset(key string, create bool) (*sortedSet, error) {
+	z, ok := c.zsetData[key]
+	if ok {
+		return z, nil
+	}
+
+	if _, ok := c.data[key]; ok {
+		return nil, ErrWrongType
+	}
+	if _, ok := c.listData[key]; ok {
+		return nil, ErrWrongType
+	}
+	if _, ok := c.streamData[key]; ok {
+		return nil, ErrWrongType
+	}
+
+	if !create {
+		return nil, nil
+	}
+
+	z = newSortedSet()
+	c.zsetData[key] = z
+	return z, nil
+}
+
+// GeoAdd adds or updates members. mode is "", "nx" or "xx"; with ch the reply
+// also counts members whose position changed.
+func (c *cache) GeoAdd(key string, members []GeoMember, mode string, ch bool) (int, error) {
+	z, err := c.zset(key, mode != "xx")
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	var count int
+	for _, m := range members {
+		_, exists := z.score(m.Name)
+		if (mode == "nx" && exists) || (mode == "xx" && !exists) {
+			continue
+		}
+
+		added, updated := z.add(m.Name, float64(geoEncode(m.Lon, m.Lat)))
+		if added || (ch && updated) {
+			count++
+		}
+		if added || updated {
+			c.touch(key)
+		}
+	}
+
+	if z.len() == 0 {
+		delete(c.zsetData, key)
+	}
+	return count, nil
+}
+
+// GeoPos returns a [lon, lat] pair per member, or nil for missing members.
+func (c *cache) GeoPos(key string, members []string) ([]any, error) {
+	z, err := c.zset(key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, len(members))
+	for i, m := range members {
+		if z == nil {
+			continue
+		}
+
+		score, ok := z.score(m)
+		if !ok {
+			continue
+		}
+
+		lon, lat := geoDecode(uint64(score))
+		res[i] = []any{lon, lat}
+	}
+	return res, nil
+}
+
+// GeoDist returns the distance in meters between two members.
+func (c *cache) GeoDist(key, from, to string) (float64, bool, error) {
+	z, err := c.zset(key, false)
+	if err != nil || z == nil {
+		return 0, false, err
+	}
+
+	s1, ok1 := z.score(from)
+	s2, ok2 := z.score(to)
+	if !ok1 || !ok2 {
+		return 0, false, nil
+	}
+
+	lon1, lat1 := geoDecode(uint64(s1))
+	lon2, lat2 := geoDecode(uint64(s2))
+	return geoDistance(lon1, lat1, lon2, lat2), true, nil
+}
+
+// GeoHash returns the standard 11 character geohash per member, or nil for
+// missing members.
+func (c *cache) GeoHash(key string, members []string) ([]any, error) {
+	z, err := c.zset(key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, len(members))
+	for i, m := range members {
+		if z == nil {
+			continue
+		}
+
+		score, ok := z.score(m)
+		if !ok {
+			continue
+		}
+
+		lon, lat := geoDecode(uint64(score))
+		res[i] = geoHashString(lon, lat)
+	}
+	return res, nil
+}
+
+func (c *cache) GeoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	z, err := c.zset(key, false)
+	if err != nil || z == nil {
+		return nil, err
+	}
+
+	lon, lat := q.Lon, q.Lat
+	if q.Member != "" {
+		score, ok := z.score(q.Member)
+		if !ok {
+			return nil, ErrGeoMember
+		}
+		lon, lat = geoDecode(uint64(score))
+	}
+
+	radius := q.Radius
+	if q.ByBox {
+		radius = math.Hypot(q.Width/2, q.Height/2)
+	}
+
+	var res []GeoResult
+search:
+	for _, r := range geoSearchRanges(lon, lat, radius) {
+		start, _ := slices.BinarySearchFunc(z.entries, r[0], func(e zsetEntry, score float64) int {
+			return cmp.Compare(e.score, score)
+		})
+		for _, e := range z.entries[start:] {
+			if e.score >= r[1] {
+				break
+			}
+			pLon, pLat := geoDecode(uint64(e.score))
+
+			var (
+				dist float64
+				ok   bool
+			)
+			if q.ByBox {
+				dist, ok = geoInBox(lon, lat, pLon, pLat, q.Width, q.Height)
+			} else {
+				dist = geoDistance(lon, lat, pLon, pLat)
+				ok = dist <= q.Radius
+			}
+			if !ok {
+				continue
+			}
+
+			res = append(res, GeoResult{Name: e.member, Dist: dist, Hash: uint64(e.score), Lon: pLon, Lat: pLat})
+			if q.Any && q.Count > 0 && len(res) == q.Count {
+				break search
+			}
+		}
+	}
+
+	sortBy := q.Sort
+	if sortBy == "" && q.Count > 0 && !q.Any {
+		sortBy = "asc"
+	}
+
+	switch sortBy {
+	case "asc":
+		slices.SortStableFunc(res, func(a, b GeoResult) int { return compareFloat(a.Dist, b.Dist) })
+	case "desc":
+		slices.SortStableFunc(res, func(a, b GeoResult) int { return compareFloat(b.Dist, a.Dist) })
+	}
+
+	if q.Count > 0 && len(res) > q.Count {
+		res = res[:q.Count]
+	}
+	return res, nil
+}
+
+// GeoSearchStore stores the matches of q into dest, scored by geohash or, with
+// storeDist, by distance in unit, the meters in one unit of the query. An
+// empty result removes dest.
+func (c *cache) GeoSearchStore(dest, key string, q GeoQuery, storeDist bool, unit float64) (int, error) {
+	res, err := c.GeoSearch(key, q)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(res) == 0 {
+		if _, ok := c.zsetData[dest]; ok {
+			delete(c.zsetData, dest)
+			c.touch(dest)
+		}
+		return 0, nil
+	}
+
+	z := newSortedSet()
+	for _, r := range res {
+		score := float64(r.Hash)
+		if storeDist {
+			score = r.Dist / unit
+		}
+		z.add(r.Name, score)
+	}
+
+	c.Del(dest)
+	c.zsetData[dest] = z
+	c.touch(dest)
+	return len(res), nil
+}
+
+// geoSearchRanges returns the score ranges to scan for the members within
+// radius meters of lon/lat: those of the geohash cell holding the centre and
+// of its neighbours, at the finest precision where these nine cells cover the
+// whole area. Each range is [min, max).
+func geoSearchRanges(lon, lat, radius float64) [][2]float64 {
+	// The area's extent in degrees, measuring longitudes at its latitude
+	// closest to a pole, where they are shortest.
+	dLat := radius / earthRadius * 180 / math.Pi
+	dLon := 360.0
+	if edge := degToRad(min(math.Abs(lat)+dLat, 90)); math.Cos(edge) > 0 {
+		dLon = min(dLat/math.Cos(edge), 360)
+	}
+
+	latOffset, lonOffset := geoDeinterleave(geoEncode(lon, lat))
+
+	var (
+		step           = geoEstimateStep(radius, lat)
+		latIdx, lonIdx uint32
+	)
+	for ; step > 1; step-- {
+		latIdx, lonIdx = latOffset>>(geoStep-step), lonOffset>>(geoStep-step)
+
+		// The neighbours extend a cell's size past the centre cell on each
+		// side.
+		cellLat := (GeoLatMax - GeoLatMin) / float64(uint64(1)<<step)
+		cellLon := (GeoLonMax - GeoLonMin) / float64(uint64(1)<<step)
+		south := GeoLatMin + float64(latIdx)*cellLat - cellLat
+		west := GeoLonMin + float64(lonIdx)*cellLon - cellLon
+		if lat-dLat >= south && lat+dLat <= south+3*cellLat &&
+			lon-dLon >= west && lon+dLon <= west+3*cellLon {
+			break
+		}
+	}
+	latIdx, lonIdx = latOffset>>(geoStep-step), lonOffset>>(geoStep-step)
+
+	cells := uint32(1) << step
+	shift := 2 * (geoStep - step)
+	var ranges [][2]float64
+	for _, dy := range []int{-1, 0, 1} {
+		y := int(latIdx) + dy
+		if y < 0 || y >= int(cells) {
+			continue
+		}
+		for _, dx := range []int{-1, 0, 1} {
+			// Longitudes wrap around the antimeridian.
+			x := uint32(int(lonIdx)+dx+int(cells)) % cells
+			hash := geoInterleave(uint32(y), x)
+			r := [2]float64{float64(hash << shift), float64((hash + 1) << shift)}
+			if !slices.Contains(ranges, r) {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+	return ranges
+}
+
+// geoEstimateStep picks the geohash precision whose cells are about the size
+// of radius, the way Redis does, coarser near the poles where cells shrink.
+func geoEstimateStep(radius, lat float64) int {
+	if radius == 0 {
+		return geoStep
+	}
+
+	const mercatorMax = 20037726.37
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return min(max(step, 1), geoStep)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func geoEncode(lon, lat float64) uint64 {
+	return geoEncodeRange(lon, lat, GeoLatMin, GeoLatMax)
+}
+
+func geoEncodeRange(lon, lat, latMin, latMax float64) uint64 {
+	latOffset := uint32((lat - latMin) / (latMax - latMin) * (1 << geoStep))
+	lonOffset := uint32((lon - GeoLonMin) / (GeoLonMax - GeoLonMin) * (1 << geoStep))
+	return geoInterleave(latOffset, lonOffset)
+}
+
+// geoDecode returns the centre of the cell identified by hash.
+func geoDecode(hash uint64) (float64, float64) {
+	latOffset, lonOffset := geoDeinterleave(hash)
+
+	latScale := GeoLatMax - GeoLatMin
+	lonScale := GeoLonMax - GeoLonMin
+
+	latMin := GeoLatMin + float64(latOffset)/(1<<geoStep)*latScale
+	latMax := GeoLatMin + float64(latOffset+1)/(1<<geoStep)*latScale
+	lonMin := GeoLonMin + float64(lonOffset)/(1<<geoStep)*lonScale
+	lonMax := GeoLonMin + float64(lonOffset+1)/(1<<geoStep)*lonScale
+
+	lon := min(max((lonMin+lonMax)/2, GeoLonMin), GeoLonMax)
+	lat := min(max((latMin+latMax)/2, GeoLatMin), GeoLatMax)
+	return lon, lat
+}
+
+func geoInterleave(lat, lon uint32) uint64 {
+	var res uint64
+	for i := 0; i < geoStep; i++ {
+		res |= uint64(lat>>i&1) << (2 * i)
+		res |= uint64(lon>>i&1) << (2*i + 1)
+	}
+	return res
+}
+
+func geoDeinterleave(hash uint64) (uint32, uint32) {
+	var lat, lon uint32
+	for i := 0; i < geoStep; i++ {
+		lat |= uint32(hash>>(2*i)&1) << i
+		lon |= uint32(hash>>(2*i+1)&1) << i
+	}
+	return lat, lon
+}
+
+// geoHashString re-encodes the position against the standard [-90, 90]
+// latitude range so the result matches geohash.org.
+func geoHashString(lon, lat float64) string {
+	hash := geoEncodeRange(lon, lat, -90, 90)
+
+	buf := make([]byte, geoHashStrSize)
+	for i := range buf {
+		var idx uint64
+		if i < geoHashStrSize-1 {
+			idx = hash >> (geoStep*2 - (i+1)*5) & 0x1f
+		}
+		buf[i] = geoHashChars[idx]
+	}
+	return string(buf)
+}
+
+func geoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lon1r := degToRad(lat1), degToRad(lon1)
+	lat2r, lon2r := degToRad(lat2), degToRad(lon2)
+
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// geoInBox checks the point against a width x height box centred on lon/lat,
+// measuring the horizontal extent along the point's own parallel.
+func geoInBox(lon, lat, pLon, pLat, width, height float64) (float64, bool) {
+	latDist := earthRadius * math.Abs(degToRad(pLat)-degToRad(lat))
+	if latDist > height/2 {
+		return 0, false
+	}
+
+	lonDist := geoDistance(pLon, pLat, lon, pLat)
+	if lonDist > width/2 {
+		return 0, false
+	}
+
+	return geoDistance(lon, lat, pLon, pLat), true
+}
+
+func degToRad(d float64) float64 {
+	return d * math.Pi / 180
+}
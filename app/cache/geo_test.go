@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSicily(t *testing.T) Cache {
+	c := New()
+	n, err := c.GeoAdd("Sicily", []GeoMember{
+		{Name: "Palermo", Lon: 13.361389, Lat: 38.115556},
+		{Name: "Catania", Lon: 15.087269, Lat: 37.502669},
+	}, "", false)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	return c
+}
+
+func TestGeoAddStoresGeohashScores(t *testing.T) {
+	c := newSicily(t)
+
+	assert.Equal(t, "zset", c.Type("Sicily"))
+
+	res, err := c.GeoSearch("Sicily", GeoQuery{Member: "Palermo", Radius: 1})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, uint64(3479099956230698), res[0].Hash)
+}
+
+func TestGeoDistAndHashMatchRedis(t *testing.T) {
+	c := newSicily(t)
+
+	dist, ok, err := c.GeoDist("Sicily", "Palermo", "Catania")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.InDelta(t, 166274.1516, dist, 0.0001)
+
+	hashes, err := c.GeoHash("Sicily", []string{"Palermo", "Catania", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"sqc8b49rny0", "sqdtr74hyu0", nil}, hashes)
+}
+
+func TestGeoSearchSortsAndLimits(t *testing.T) {
+	c := newSicily(t)
+
+	res, err := c.GeoSearch("Sicily", GeoQuery{Lon: 15, Lat: 37, Radius: 200000, Sort: "desc"})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "Palermo", res[0].Name)
+	assert.Equal(t, "Catania", res[1].Name)
+
+	res, err = c.GeoSearch("Sicily", GeoQuery{Lon: 15, Lat: 37, Radius: 200000, Count: 1})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, "Catania", res[0].Name)
+
+	res, err = c.GeoSearch("Sicily", GeoQuery{Lon: 15, Lat: 37, ByBox: true, Width: 200000, Height: 200000})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, "Catania", res[0].Name)
+}
+
+func TestGeoSearchStoreWritesDistances(t *testing.T) {
+	c := newSicily(t)
+
+	n, err := c.GeoSearchStore("near", "Sicily", GeoQuery{Lon: 15, Lat: 37, Radius: 100000}, true, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	score, ok := c.(*cache).zsetData["near"].score("Catania")
+	require.True(t, ok)
+	assert.InDelta(t, 56.4413, score, 0.0001, "stored in the unit of the query")
+
+	n, err = c.GeoSearchStore("near", "Sicily", GeoQuery{Lon: 0, Lat: 0, Radius: 1}, false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "none", c.Type("near"))
+}
+
+// TestGeoSearchMatchesFullScan checks that the geohash ranges searched cover
+// every match, around the poles and the antimeridian too.
+func TestGeoSearchMatchesFullScan(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	c := New()
+
+	var members []GeoMember
+	for i := range 2000 {
+		members = append(members, GeoMember{
+			Name: fmt.Sprint(i),
+			Lon:  GeoLonMin + rnd.Float64()*(GeoLonMax-GeoLonMin),
+			Lat:  GeoLatMin + rnd.Float64()*(GeoLatMax-GeoLatMin),
+		})
+	}
+	_, err := c.GeoAdd("points", members, "", false)
+	require.NoError(t, err)
+
+	for i := range 300 {
+		q := GeoQuery{
+			Lon:    GeoLonMin + rnd.Float64()*(GeoLonMax-GeoLonMin),
+			Lat:    GeoLatMin + rnd.Float64()*(GeoLatMax-GeoLatMin),
+			Radius: math.Pow(10, 3+rnd.Float64()*4),
+		}
+		if i%3 == 0 {
+			q.Lon = 179.9
+		}
+		if i%2 == 1 {
+			q.ByBox, q.Width, q.Height = true, q.Radius, 2*q.Radius*rnd.Float64()
+		}
+
+		var want []string
+		for _, m := range c.(*cache).zsetData["points"].entries {
+			lon, lat := geoDecode(uint64(m.score))
+			if q.ByBox {
+				if _, ok := geoInBox(q.Lon, q.Lat, lon, lat, q.Width, q.Height); ok {
+					want = append(want, m.member)
+				}
+			} else if geoDistance(q.Lon, q.Lat, lon, lat) <= q.Radius {
+				want = append(want, m.member)
+			}
+		}
+
+		res, err := c.GeoSearch("points", q)
+		require.NoError(t, err)
+		var got []string
+		for _, r := range res {
+			got = append(got, r.Name)
+		}
+		slices.Sort(want)
+		slices.Sort(got)
+		assert.Equal(t, want, got, "%+v", q)
+	}
+}
@@ -0,0 +1,404 @@
+package cache
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// HyperLogLogs are stored as plain strings using the same layout as Redis: a
+// 16 byte header ("HYLL", encoding, 3 unused bytes, 8 byte little endian
+// cached cardinality) followed by either 16384 packed 6-bit registers (dense)
+// or a run-length encoded register list (sparse).
+const (
+	hllP              = 14
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllPMask          = hllRegisters - 1
+	hllBits           = 6
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHeaderSize     = 16
+	hllDenseSize      = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllDense          = 0
+	hllSparse         = 1
+	hllSparseValMax   = 32
+	hllSparseMaxBytes = 3000
+	hllAlphaInf       = 0.721347520444481703680
+	hllMagic          = "HYLL"
+	hllHashSeed       = 0xadc83b19
+)
+
+// Sparse opcodes, see hllSparseEncode.
+const (
+	hllOpZero    = 0x00 // 00xxxxxx: 1-64 zero registers
+	hllOpXZero   = 0x40 // 01xxxxxx yyyyyyyy: 1-16384 zero registers
+	hllOpVal     = 0x80 // 1vvvvvxx: 1-4 registers set to 1-32
+	hllZeroMax   = 64
+	hllXZeroMax  = hllRegisters
+	hllValRunMax = 4
+)
+
+func (c *cache) PFAdd(key string, elems []string) (int, error) {
+	s, exists, err := c.stringValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		regs     []uint8
+		encoding byte = hllSparse
+		changed  bool
+	)
+	if exists {
+		regs, encoding, err = hllDecode(s)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		regs = make([]uint8, hllRegisters)
+		changed = true
+	}
+
+	for _, e := range elems {
+		idx, count := hllPatLen([]byte(e))
+		if count > regs[idx] {
+			regs[idx] = count
+			changed = true
+		}
+	}
+
+	if !changed {
+		return 0, nil
+	}
+
+	buf := hllEncode(regs, encoding)
+	if !exists && len(elems) == 0 {
+		hllSetCardinality(buf, 0)
+	}
+
+	c.data[key] = string(buf)
+	c.touch(key)
+	return 1, nil
+}
+
+// PFCount estimates the cardinality of the union of keys. With a single key
+// the estimate is cached in the header until the next PFADD invalidates it.
+func (c *cache) PFCount(keys []string) (int, error) {
+	if len(keys) == 1 {
+		s, exists, err := c.stringValue(keys[0])
+		if err != nil || !exists {
+			return 0, err
+		}
+
+		regs, _, err := hllDecode(s)
+		if err != nil {
+			return 0, err
+		}
+
+		buf := []byte(s)
+		if card, ok := hllCachedCardinality(buf); ok {
+			return int(card), nil
+		}
+
+		card := hllCount(regs)
+		hllSetCardinality(buf, card)
+		c.data[keys[0]] = string(buf)
+		return int(card), nil
+	}
+
+	regs, err := c.hllUnion(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(hllCount(regs)), nil
+}
+
+func (c *cache) PFMerge(dest string, keys []string) error {
+	regs, err := c.hllUnion(append([]string{dest}, keys...))
+	if err != nil {
+		return err
+	}
+
+	c.data[dest] = string(hllEncode(regs, hllDense))
+	c.touch(dest)
+	return nil
+}
+
+func (c *cache) hllUnion(keys []string) ([]uint8, error) {
+	res := make([]uint8, hllRegisters)
+	for _, key := range keys {
+		s, exists, err := c.stringValue(key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		regs, _, err := hllDecode(s)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, r := range regs {
+			res[i] = max(res[i], r)
+		}
+	}
+	return res, nil
+}
+
+func hllDecode(s string) ([]uint8, byte, error) {
+	buf := []byte(s)
+	if len(buf) < hllHeaderSize || string(buf[:4]) != hllMagic {
+		return nil, 0, ErrInvalidHLL
+	}
+
+	regs := make([]uint8, hllRegisters)
+	switch buf[4] {
+	case hllDense:
+		if len(buf) != hllDenseSize {
+			return nil, 0, ErrInvalidHLL
+		}
+
+		for i := range regs {
+			regs[i] = hllDenseGet(buf[hllHeaderSize:], i)
+		}
+	case hllSparse:
+		idx := 0
+		for p := hllHeaderSize; p < len(buf); p++ {
+			op := buf[p]
+			switch {
+			case op&0xc0 == hllOpZero:
+				idx += int(op&0x3f) + 1
+			case op&0xc0 == hllOpXZero:
+				if p+1 >= len(buf) {
+					return nil, 0, ErrInvalidHLL
+				}
+				idx += (int(op&0x3f)<<8 | int(buf[p+1])) + 1
+				p++
+			default:
+				val := (op>>2)&0x1f + 1
+				run := int(op&0x03) + 1
+				if idx+run > hllRegisters {
+					return nil, 0, ErrInvalidHLL
+				}
+				for j := 0; j < run; j++ {
+					regs[idx+j] = val
+				}
+				idx += run
+			}
+		}
+
+		if idx != hllRegisters {
+			return nil, 0, ErrInvalidHLL
+		}
+	default:
+		return nil, 0, ErrInvalidHLL
+	}
+
+	return regs, buf[4], nil
+}
+
+// hllEncode serialises regs with an invalidated cardinality cache. Sparse is
+// only kept while every register fits the VAL opcode and the result stays
+// below hllSparseMaxBytes; once an HLL is dense it never goes back.
+func hllEncode(regs []uint8, encoding byte) []byte {
+	if encoding == hllSparse {
+		if buf, ok := hllSparseEncode(regs); ok {
+			return buf
+		}
+	}
+
+	buf := make([]byte, hllDenseSize)
+	hllWriteHeader(buf, hllDense)
+	for i, r := range regs {
+		hllDenseSet(buf[hllHeaderSize:], i, r)
+	}
+	return buf
+}
+
+func hllSparseEncode(regs []uint8) ([]byte, bool) {
+	buf := make([]byte, hllHeaderSize, hllHeaderSize+64)
+	hllWriteHeader(buf, hllSparse)
+
+	for i := 0; i < len(regs); {
+		val := regs[i]
+		if val > hllSparseValMax {
+			return nil, false
+		}
+
+		run := 1
+		for i+run < len(regs) && regs[i+run] == val {
+			run++
+		}
+		i += run
+
+		for run > 0 {
+			switch {
+			case val != 0:
+				n := min(run, hllValRunMax)
+				buf = append(buf, hllOpVal|(val-1)<<2|byte(n-1))
+				run -= n
+			case run > hllZeroMax:
+				n := min(run, hllXZeroMax)
+				buf = append(buf, hllOpXZero|byte((n-1)>>8), byte(n-1))
+				run -= n
+			default:
+				buf = append(buf, hllOpZero|byte(run-1))
+				run = 0
+			}
+		}
+
+		if len(buf) > hllSparseMaxBytes {
+			return nil, false
+		}
+	}
+
+	return buf, true
+}
+
+func hllWriteHeader(buf []byte, encoding byte) {
+	copy(buf, hllMagic)
+	buf[4] = encoding
+	buf[15] |= 1 << 7
+}
+
+func hllCachedCardinality(buf []byte) (uint64, bool) {
+	if buf[15]&(1<<7) != 0 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(buf[8:16]), true
+}
+
+func hllSetCardinality(buf []byte, card uint64) {
+	binary.LittleEndian.PutUint64(buf[8:16], card)
+}
+
+// Dense registers are packed least significant bit first, so a register may
+// straddle two bytes.
+func hllDenseGet(regs []byte, idx int) uint8 {
+	byteIdx := idx * hllBits / 8
+	fb := uint(idx * hllBits & 7)
+
+	v := uint(regs[byteIdx]) >> fb
+	if byteIdx+1 < len(regs) {
+		v |= uint(regs[byteIdx+1]) << (8 - fb)
+	}
+	return uint8(v & hllRegisterMax)
+}
+
+func hllDenseSet(regs []byte, idx int, val uint8) {
+	byteIdx := idx * hllBits / 8
+	fb := uint(idx * hllBits & 7)
+	v := uint(val)
+
+	regs[byteIdx] &^= byte(hllRegisterMax << fb)
+	regs[byteIdx] |= byte(v << fb)
+	if byteIdx+1 < len(regs) {
+		regs[byteIdx+1] &^= byte(hllRegisterMax >> (8 - fb))
+		regs[byteIdx+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// hllPatLen returns the register for elem and the length of the 000..1 pattern
+// in the remaining hash bits.
+func hllPatLen(elem []byte) (int, uint8) {
+	hash := murmurHash64A(elem, hllHashSeed)
+	idx := int(hash & hllPMask)
+
+	hash >>= hllP
+	hash |= 1 << hllQ
+
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return idx, count
+}
+
+// hllCount implements the estimator from Otmar Ertl's "New cardinality
+// estimation algorithms for HyperLogLog sketches", as Redis does.
+func hllCount(regs []uint8) uint64 {
+	m := float64(hllRegisters)
+
+	var histogram [64]int
+	for _, r := range regs {
+		histogram[r]++
+	}
+
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+
+	h := seed ^ uint64(len(key))*m
+
+	n := len(key) - len(key)%8
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+	}
+
+	tail := key[n:]
+	if len(tail) > 0 {
+		for i := len(tail) - 1; i >= 0; i-- {
+			h ^= uint64(tail[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
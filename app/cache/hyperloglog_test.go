@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPFAddReportsRegisterChanges(t *testing.T) {
+	c := New()
+
+	n, err := c.PFAdd("hll", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = c.PFAdd("hll", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	count, err := c.PFCount([]string{"hll"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	val, _ := c.Get("hll")
+	assert.Equal(t, "HYLL", val.(string)[:4])
+	assert.Equal(t, byte(hllSparse), val.(string)[4])
+}
+
+func TestPFCountStaysWithinStandardError(t *testing.T) {
+	c := New()
+
+	const total = 100000
+	elems := make([]string, 0, 1000)
+	for i := 0; i < total; i++ {
+		elems = append(elems, "elem:"+strconv.Itoa(i))
+		if len(elems) == cap(elems) {
+			_, err := c.PFAdd("hll", elems)
+			require.NoError(t, err)
+			elems = elems[:0]
+		}
+	}
+
+	val, _ := c.Get("hll")
+	assert.Len(t, val, hllDenseSize)
+
+	count, err := c.PFCount([]string{"hll"})
+	require.NoError(t, err)
+	assert.InEpsilon(t, total, count, 0.0081*3)
+}
+
+func TestPFMergeUnionsSourcesIntoDense(t *testing.T) {
+	c := New()
+	_, _ = c.PFAdd("a", []string{"1", "2", "3"})
+	_, _ = c.PFAdd("b", []string{"3", "4"})
+
+	require.NoError(t, c.PFMerge("dest", []string{"a", "b"}))
+
+	count, err := c.PFCount([]string{"dest"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	count, err = c.PFCount([]string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+}
+
+func TestHLLSparseRoundTrip(t *testing.T) {
+	regs := make([]uint8, hllRegisters)
+	regs[0], regs[1], regs[500], regs[hllRegisters-1] = 3, 3, 32, 1
+
+	buf, ok := hllSparseEncode(regs)
+	require.True(t, ok)
+
+	got, encoding, err := hllDecode(string(buf))
+	require.NoError(t, err)
+	assert.Equal(t, byte(hllSparse), encoding)
+	assert.Equal(t, regs, got)
+}
+
+func TestPFAddRejectsPlainStrings(t *testing.T) {
+	c := New()
+	c.Set("s", "hello")
+
+	_, err := c.PFAdd("s", []string{"a"})
+	assert.ErrorIs(t, err, ErrInvalidHLL)
+}
@@ -0,0 +1,160 @@
+package cache
+
+import "time"
+
+// Exists reports whether key holds a value of any type. Lists emptied by
+// popping are gone.
+func (c *cache) Exists(key string) bool {
+	if _, ok := c.data[key]; ok {
+		return true
+	}
+	if len(c.listData[key]) > 0 {
+		return true
+	}
+	if _, ok := c.streamData[key]; ok {
+		return true
+	}
+	_, ok := c.zsetData[key]
+	return ok
+}
+
+// Len returns the number of keys.
+func (c *cache) Len() int {
+	n := len(c.data)
+	for key, v := range c.listData {
+		if _, ok := c.data[key]; !ok && len(v) > 0 {
+			n++
+		}
+	}
+	for key := range c.streamData {
+		if _, ok := c.data[key]; !ok && len(c.listData[key]) == 0 {
+			n++
+		}
+	}
+	for key := range c.zsetData {
+		if _, ok := c.data[key]; !ok && len(c.listData[key]) == 0 {
+			if _, ok := c.streamData[key]; !ok {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Flush removes every key. The old data is left to the garbage collector, so
+// flushing takes the same time however many keys there were. Clients blocked
+// on keys keep waiting.
+func (c *cache) Flush() {
+	for key := range c.watched {
+		if c.Exists(key) {
+			c.touch(key)
+		}
+	}
+
+	c.data = make(map[any]any)
+	c.listData = make(map[any][]any)
+	c.streamData = make(map[any]*stream)
+	c.zsetData = make(map[any]*sortedSet)
+	c.expires = make(map[string]time.Time)
+	c.meta = make(map[string]*keyMeta)
+	clear(c.dirty)
+	c.used = 0
+}
+
+// Move moves key to dst, which must have been created by New too. Nothing
+// is moved if key doesn't exist or dst already has it.
+func (c *cache) Move(key string, dst Cache) bool {
+	d := dst.(*cache)
+	if !c.Exists(key) || d.Exists(key) {
+		return false
+	}
+
+	if v, ok := c.data[key]; ok {
+		d.data[key] = v
+		delete(c.data, key)
+	}
+	if v, ok := c.listData[key]; ok {
+		d.listData[key] = v
+		delete(c.listData, key)
+	}
+	if v, ok := c.streamData[key]; ok {
+		d.streamData[key] = v
+		delete(c.streamData, key)
+	}
+	if v, ok := c.zsetData[key]; ok {
+		d.zsetData[key] = v
+		delete(c.zsetData, key)
+	}
+	if at, ok := c.expires[key]; ok {
+		d.expires[key] = at
+		delete(c.expires, key)
+	}
+
+	c.touch(key)
+	d.touch(key)
+	return true
+}
+
+// Swap exchanges the keys of c and other, which must have been created by
+// New too. Like WATCH, blocked clients wait on a database rather than on its
+// keys, so they stay where they are and those whose keys now have data are
+// served.
+func (c *cache) Swap(other Cache) {
+	o := other.(*cache)
+
+	c.data, o.data = o.data, c.data
+	c.listData, o.listData = o.listData, c.listData
+	c.streamData, o.streamData = o.streamData, c.streamData
+	c.zsetData, o.zsetData = o.zsetData, c.zsetData
+	c.expires, o.expires = o.expires, c.expires
+	c.meta, o.meta = o.meta, c.meta
+	c.dirty, o.dirty = o.dirty, c.dirty
+	c.used, o.used = o.used, c.used
+
+	c.signalAllWaiters()
+	o.signalAllWaiters()
+}
+
+// Remove deletes key whatever the type of its value, reporting whether it
+// existed.
+func (c *cache) Remove(key string) bool {
+	if !c.Exists(key) {
+		return false
+	}
+
+	delete(c.data, key)
+	delete(c.listData, key)
+	delete(c.streamData, key)
+	delete(c.zsetData, key)
+	delete(c.expires, key)
+	c.touch(key)
+	return true
+}
+
+// Expire sets when key expires. Removing the key then is up to the caller.
+func (c *cache) Expire(key string, at time.Time) {
+	if c.Exists(key) {
+		c.expires[key] = at
+	}
+}
+
+// ExpiresAt returns the expiry of key, if it has one.
+func (c *cache) ExpiresAt(key string) (time.Time, bool) {
+	at, ok := c.expires[key]
+	return at, ok
+}
+
+// ExpiringKeys returns the number of keys with an expiry and their average
+// time to live.
+func (c *cache) ExpiringKeys() (int, time.Duration) {
+	if len(c.expires) == 0 {
+		return 0, 0
+	}
+
+	now := time.Now()
+	var total time.Duration
+	for _, at := range c.expires {
+		total += max(at.Sub(now), 0)
+	}
+	return len(c.expires), total / time.Duration(len(c.expires))
+}
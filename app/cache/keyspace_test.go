@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLenCountsEveryType(t *testing.T) {
+	c := New()
+	c.Set("s", "1")
+	c.RPush("l", []any{"a"})
+	c.XAdd("x", "*", []any{"a", "1"})
+	c.GeoAdd("g", []GeoMember{{Name: "a", Lon: 1, Lat: 1}}, "", false)
+	assert.Equal(t, 4, c.Len())
+
+	c.LPop("l", nil)
+	assert.False(t, c.Exists("l"))
+	assert.Equal(t, 3, c.Len())
+}
+
+func TestFlushTouchesWatchedKeys(t *testing.T) {
+	c := New()
+	c.Set("k", "1")
+	v, missing := c.Watch("k"), c.Watch("missing")
+
+	c.Flush()
+	assert.Zero(t, c.Len())
+	assert.NotEqual(t, v, c.KeyVersion("k"))
+	assert.Equal(t, missing, c.KeyVersion("missing"))
+}
+
+func TestMove(t *testing.T) {
+	src, dst := New(), New()
+	src.RPush("l", []any{"a", "b"})
+	src.Set("s", "1")
+	dst.Set("s", "2")
+
+	assert.True(t, src.Move("l", dst))
+	assert.False(t, src.Exists("l"))
+	assert.Equal(t, []any{"a", "b"}, dst.LRange("l", 0, -1))
+
+	assert.False(t, src.Move("s", dst), "existing destination key")
+	assert.False(t, src.Move("missing", dst))
+	v, _ := dst.Get("s")
+	assert.Equal(t, "2", v)
+}
+
+func TestExpiringKeys(t *testing.T) {
+	c := New()
+	n, ttl := c.ExpiringKeys()
+	assert.Zero(t, n)
+	assert.Zero(t, ttl)
+
+	c.Set("a", "1")
+	c.Set("b", "1")
+	c.Set("c", "1")
+	c.Expire("a", time.Now().Add(time.Hour))
+	c.Expire("b", time.Now().Add(3*time.Hour))
+
+	n, ttl = c.ExpiringKeys()
+	assert.Equal(t, 2, n)
+	assert.InDelta(t, 2*time.Hour, ttl, float64(time.Second))
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// The overheads approximate what Redis allocates besides the data itself, so
+// that estimates are in the same ballpark as MEMORY USAGE there.
+const (
+	// keyOverhead covers the dictionary entry, the object header and the
+	// key's string header.
+	keyOverhead = 56
+	// stringOverhead is the header of a string value or list element.
+	stringOverhead = 16
+	// collectionOverhead is the header of a list, stream or sorted set.
+	collectionOverhead = 64
+	// zsetEntryOverhead covers the skiplist node and dictionary entry of a
+	// sorted set member.
+	zsetEntryOverhead = 48
+	// streamEntryOverhead covers the id and the listpack framing of an entry.
+	streamEntryOverhead = 24
+	// pendingEntryOverhead is the size of an entry in a consumer group's
+	// pending entries list.
+	pendingEntryOverhead = 64
+
+	// DefaultSamples is how many elements of a collection are looked at to
+	// estimate its size.
+	DefaultSamples = 5
+)
+
+// The LFU counter grows logarithmically with the accesses and decrements once
+// per decay period without any, as with Redis' default lfu-log-factor and
+// lfu-decay-time.
+const (
+	lfuInitial   = 5
+	lfuLogFactor = 10
+	lfuDecay     = time.Minute
+)
+
+// keyMeta is what the cache knows about a key besides its value.
+type keyMeta struct {
+	size       int
+	lastAccess time.Time
+	freq       uint8
+	decayedAt  time.Time
+}
+
+// KeyStats describes a key for eviction and introspection.
+type KeyStats struct {
+	Key string
+	// Size is the estimated memory usage of the key and its value, in
+	// bytes.
+	Size int
+	// LastAccess is when the key was last read or written.
+	LastAccess time.Time
+	// Freq is the logarithmic access frequency counter, after decay.
+	Freq int
+	// ExpiresAt is zero for keys without an expiry.
+	ExpiresAt time.Time
+}
+
+// UsedMemory is the estimated memory used by all keys, in bytes.
+func (c *cache) UsedMemory() int {
+	c.settle()
+	return c.used
+}
+
+// MemoryUsage estimates the memory used by key, looking at up to samples
+// elements of a collection, or all of them if samples is 0.
+func (c *cache) MemoryUsage(key string, samples int) (int, bool) {
+	if !c.Exists(key) {
+		return 0, false
+	}
+	return c.estimate(key, samples), true
+}
+
+// Access records a read or write of key for the LRU and LFU policies.
+func (c *cache) Access(key string) {
+	c.settle()
+	m, ok := c.meta[key]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	m.freq = m.decayedFreq(now)
+	m.decayedAt = now
+	if m.freq < 255 {
+		base := max(float64(m.freq)-lfuInitial, 0)
+		if rand.Float64() < 1/(base*lfuLogFactor+1) {
+			m.freq++
+		}
+	}
+	m.lastAccess = now
+}
+
+// decayedFreq is the LFU counter decremented once per decay period elapsed
+// since the last access.
+func (m *keyMeta) decayedFreq(now time.Time) uint8 {
+	periods := int(now.Sub(m.decayedAt) / lfuDecay)
+	return uint8(max(int(m.freq)-periods, 0))
+}
+
+// Stats describes key without counting as an access.
+func (c *cache) Stats(key string) (KeyStats, bool) {
+	c.settle()
+	m, ok := c.meta[key]
+	if !ok {
+		return KeyStats{}, false
+	}
+	return c.stats(key, m), true
+}
+
+func (c *cache) stats(key string, m *keyMeta) KeyStats {
+	return KeyStats{
+		Key:        key,
+		Size:       m.size,
+		LastAccess: m.lastAccess,
+		Freq:       int(m.decayedFreq(time.Now())),
+		ExpiresAt:  c.expires[key],
+	}
+}
+
+// Sample returns up to n keys picked at random, only among those with an
+// expiry if volatile is set. Like Redis, it relies on the randomness of map
+// iteration rather than a uniform draw.
+func (c *cache) Sample(n int, volatile bool) []KeyStats {
+	c.settle()
+
+	res := make([]KeyStats, 0, n)
+	if volatile {
+		for key := range c.expires {
+			if len(res) == n {
+				break
+			}
+			if m, ok := c.meta[key]; ok {
+				res = append(res, c.stats(key, m))
+			}
+		}
+		return res
+	}
+
+	for key, m := range c.meta {
+		if len(res) == n {
+			break
+		}
+		res = append(res, c.stats(key, m))
+	}
+	return res
+}
+
+// settle updates the size of the keys written since the last call. Writes
+// only mark keys, as some record the write before making it.
+func (c *cache) settle() {
+	for key := range c.dirty {
+		m, ok := c.meta[key]
+		if !c.Exists(key) {
+			if ok {
+				c.used -= m.size
+				delete(c.meta, key)
+			}
+			continue
+		}
+
+		if !ok {
+			now := time.Now()
+			m = &keyMeta{lastAccess: now, freq: lfuInitial, decayedAt: now}
+			c.meta[key] = m
+		}
+		size := c.estimate(key, DefaultSamples)
+		c.used += size - m.size
+		m.size = size
+	}
+	clear(c.dirty)
+}
+
+// estimate approximates the memory used by key and its value from up to
+// samples elements of a collection, or all of them if samples is 0.
+func (c *cache) estimate(key string, samples int) int {
+	n := keyOverhead + len(key)
+
+	if v, ok := c.data[key]; ok {
+		n += valueSize(v)
+	}
+	if l := c.listData[key]; len(l) > 0 {
+		n += collectionOverhead + sampledSize(len(l), samples, func(i int) int {
+			return valueSize(l[i])
+		})
+	}
+	if s, ok := c.streamData[key]; ok {
+		n += collectionOverhead + s.estimate(samples)
+	}
+	if z, ok := c.zsetData[key]; ok {
+		n += collectionOverhead + sampledSize(z.len(), samples, func(i int) int {
+			return zsetEntryOverhead + len(z.entries[i].member)
+		})
+	}
+	return n
+}
+
+func (s *stream) estimate(samples int) int {
+	var entries []streamEntry
+	for _, node := range s.nodes {
+		if samples > 0 && len(entries) >= samples {
+			break
+		}
+		entries = append(entries, node.entries...)
+	}
+
+	n := sampledSize(s.length, min(samples, len(entries)), func(i int) int {
+		size := streamEntryOverhead
+		for _, f := range entries[i].fields {
+			size += valueSize(f)
+		}
+		return size
+	})
+	for _, g := range s.groups {
+		n += collectionOverhead + len(g.name) + g.pel.len()*pendingEntryOverhead
+		for name := range g.consumers {
+			n += collectionOverhead + len(name)
+		}
+	}
+	return n
+}
+
+// sampledSize extrapolates the size of n elements from the first samples of
+// them, or adds them all up if samples is 0.
+func sampledSize(n, samples int, size func(i int) int) int {
+	if samples <= 0 || samples > n {
+		samples = n
+	}
+	if samples == 0 {
+		return 0
+	}
+
+	var total int
+	for i := range samples {
+		total += size(i)
+	}
+	return total * n / samples
+}
+
+func valueSize(v any) int {
+	if s, ok := v.(string); ok {
+		return stringOverhead + len(s)
+	}
+	return stringOverhead
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsedMemoryFollowsWrites(t *testing.T) {
+	c := New()
+	assert.Zero(t, c.UsedMemory())
+
+	c.Set("k", strings.Repeat("x", 100))
+	used := c.UsedMemory()
+	assert.Equal(t, keyOverhead+1+stringOverhead+100, used)
+
+	c.RPush("l", []any{"a", "b"})
+	assert.Greater(t, c.UsedMemory(), used)
+
+	c.LPop("l", nil)
+	c.LPop("l", nil)
+	assert.Equal(t, used, c.UsedMemory())
+
+	c.Del("k")
+	assert.Zero(t, c.UsedMemory())
+}
+
+func TestMemoryUsageSamples(t *testing.T) {
+	c := New()
+	c.RPush("l", []any{"a", "b", "c", strings.Repeat("x", 1000)})
+
+	sampled, ok := c.MemoryUsage("l", 1)
+	require.True(t, ok)
+	all, _ := c.MemoryUsage("l", 0)
+	assert.Less(t, sampled, all)
+
+	_, ok = c.MemoryUsage("missing", 0)
+	assert.False(t, ok)
+}
+
+func TestSampleVolatileKeys(t *testing.T) {
+	c := New()
+	c.Set("persistent", "1")
+	c.Set("volatile", "1")
+	at := time.Now().Add(time.Hour)
+	c.Expire("volatile", at)
+
+	keys := c.Sample(10, true)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "volatile", keys[0].Key)
+	assert.True(t, keys[0].ExpiresAt.Equal(at))
+	assert.Len(t, c.Sample(10, false), 2)
+
+	c.Set("volatile", "2")
+	assert.Empty(t, c.Sample(10, true), "SET clears the expiry")
+}
+
+func TestAccessUpdatesStats(t *testing.T) {
+	c := New()
+	c.Set("k", "1")
+	before, ok := c.Stats("k")
+	require.True(t, ok)
+	assert.Equal(t, lfuInitial, before.Freq)
+
+	time.Sleep(time.Millisecond)
+	for range 100 {
+		c.Access("k")
+	}
+	after, _ := c.Stats("k")
+	assert.True(t, after.LastAccess.After(before.LastAccess))
+	assert.Greater(t, after.Freq, before.Freq)
+}
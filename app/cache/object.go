@@ -0,0 +1,57 @@
+package cache
+
+import "strconv"
+
+// The thresholds below which Redis keeps values in their compact encodings,
+// with the default configuration.
+const (
+	embstrMaxLen         = 44
+	listpackMaxEntries   = 128
+	listpackMaxBytes     = 8 << 10
+	zsetListpackMaxValue = 64
+)
+
+// Encoding returns the encoding Redis would use for the value of key, as
+// reported by OBJECT ENCODING.
+func (c *cache) Encoding(key string) (string, bool) {
+	if v, ok := c.data[key]; ok {
+		s, _ := v.(string)
+		switch {
+		case len(s) <= 20 && isInt(s):
+			return "int", true
+		case len(s) <= embstrMaxLen:
+			return "embstr", true
+		default:
+			return "raw", true
+		}
+	}
+
+	if l := c.listData[key]; len(l) > 0 {
+		if len(l) <= listpackMaxEntries && c.estimate(key, 0) <= listpackMaxBytes {
+			return "listpack", true
+		}
+		return "quicklist", true
+	}
+
+	if _, ok := c.streamData[key]; ok {
+		return "stream", true
+	}
+
+	if z, ok := c.zsetData[key]; ok {
+		if z.len() > listpackMaxEntries {
+			return "skiplist", true
+		}
+		for _, e := range z.entries {
+			if len(e.member) > zsetListpackMaxValue {
+				return "skiplist", true
+			}
+		}
+		return "listpack", true
+	}
+	return "", false
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding(t *testing.T) {
+	c := New()
+	c.Set("int", "12345")
+	c.Set("embstr", "hello")
+	c.Set("raw", strings.Repeat("x", 45))
+	c.RPush("small", []any{"a"})
+	for i := range 200 {
+		c.RPush("big", []any{fmt.Sprint(i)})
+	}
+	c.XAdd("stream", "*", []any{"a", "1"})
+	c.GeoAdd("geo", []GeoMember{{Name: "a", Lon: 1, Lat: 1}}, "", false)
+
+	for key, want := range map[string]string{
+		"int":    "int",
+		"embstr": "embstr",
+		"raw":    "raw",
+		"small":  "listpack",
+		"big":    "quicklist",
+		"stream": "stream",
+		"geo":    "listpack",
+	} {
+		enc, ok := c.Encoding(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, enc, key)
+	}
+
+	_, ok := c.Encoding("missing")
+	assert.False(t, ok)
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"cmp"
+	"iter"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// streamNodeEntries mirrors stream-node-max-entries: the number of entries
+// packed into one node, and the granularity of approximate trimming.
+const streamNodeEntries = 100
+
+// StreamID is a stream entry id, parsed once from its "ms-seq" form.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var maxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// ParseStreamID parses "ms-seq", or "ms" with a sequence of 0.
+func ParseStreamID(s string) (StreamID, error) {
+	id, ok := parseStreamID(s, 0)
+	if !ok {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	return id, nil
+}
+
+// parseStreamID parses "ms-seq" or "ms"; a missing sequence defaults to seq.
+func parseStreamID(s string, seq uint64) (StreamID, bool) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+
+	if hasSeq {
+		if seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return StreamID{}, false
+		}
+	}
+	return StreamID{Ms: ms, Seq: seq}, true
+}
+
+// parseRangeID is parseStreamID that also accepts "-" and "+".
+func parseRangeID(s string, seq uint64) (StreamID, bool) {
+	switch s {
+	case "-":
+		return StreamID{}, true
+	case "+":
+		return maxStreamID, true
+	default:
+		return parseStreamID(s, seq)
+	}
+}
+
+// parseRangeBound is parseRangeID that also accepts an id prefixed with "(",
+// reporting that the bound excludes it.
+func parseRangeBound(s string, seq uint64) (id StreamID, exclusive, ok bool) {
+	if rest, found := strings.CutPrefix(s, "("); found {
+		id, ok = parseStreamID(rest, seq)
+		return id, true, ok
+	}
+
+	id, ok = parseRangeID(s, seq)
+	return id, false, ok
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id StreamID) Compare(other StreamID) int {
+	if c := cmp.Compare(id.Ms, other.Ms); c != 0 {
+		return c
+	}
+	return cmp.Compare(id.Seq, other.Seq)
+}
+
+func (id StreamID) IsZero() bool {
+	return id == StreamID{}
+}
+
+// next returns the smallest id greater than id; ok is false for the maximum
+// id.
+func (id StreamID) next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// prev returns the largest id smaller than id; ok is false for the zero id.
+func (id StreamID) prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+type streamEntry struct {
+	id     StreamID
+	fields []any
+}
+
+// reply is the [id, [field, value, ...]] form entries are sent back in.
+func (e streamEntry) reply() [2]any {
+	return [2]any{e.id.String(), e.fields}
+}
+
+// streamNode is a run of up to streamNodeEntries consecutive entries, the
+// equivalent of a listpack in a Redis stream.
+type streamNode struct {
+	entries []streamEntry
+}
+
+// stream keeps its entries in non-empty nodes ordered by id. Lookups binary
+// search the node index and then the node, so a range costs O(log n + k);
+// appends only ever touch the last node and head trimming drops whole nodes.
+type stream struct {
+	nodes        []*streamNode
+	length       int
+	lastID       StreamID
+	maxDeletedID StreamID
+	entriesAdded int
+	groups       map[string]*consumerGroup
+}
+
+func newStream() *stream {
+	return &stream{groups: make(map[string]*consumerGroup)}
+}
+
+func (s *stream) append(id StreamID, fields []any) {
+	if n := len(s.nodes); n == 0 || len(s.nodes[n-1].entries) >= streamNodeEntries {
+		s.nodes = append(s.nodes, &streamNode{entries: make([]streamEntry, 0, streamNodeEntries)})
+	}
+
+	last := s.nodes[len(s.nodes)-1]
+	last.entries = append(last.entries, streamEntry{id: id, fields: fields})
+	s.length++
+	s.lastID = id
+	s.entriesAdded++
+}
+
+// seek returns the node and offset of the first entry with an id >= id. The
+// node index equals len(s.nodes) when there is none.
+func (s *stream) seek(id StreamID) (int, int) {
+	n := sort.Search(len(s.nodes), func(i int) bool {
+		entries := s.nodes[i].entries
+		return entries[len(entries)-1].id.Compare(id) >= 0
+	})
+	if n == len(s.nodes) {
+		return n, 0
+	}
+
+	entries := s.nodes[n].entries
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].id.Compare(id) >= 0
+	})
+	return n, i
+}
+
+// ascend yields entries with an id >= from in order.
+func (s *stream) ascend(from StreamID) iter.Seq[streamEntry] {
+	return func(yield func(streamEntry) bool) {
+		n, i := s.seek(from)
+		for ; n < len(s.nodes); n, i = n+1, 0 {
+			for _, e := range s.nodes[n].entries[i:] {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ascendAfter yields entries with an id > after in order.
+func (s *stream) ascendAfter(after StreamID) iter.Seq[streamEntry] {
+	from, ok := after.next()
+	if !ok {
+		return func(func(streamEntry) bool) {}
+	}
+	return s.ascend(from)
+}
+
+// descend yields entries with an id <= from in reverse order.
+func (s *stream) descend(from StreamID) iter.Seq[streamEntry] {
+	return func(yield func(streamEntry) bool) {
+		n, i := len(s.nodes), 0
+		if next, ok := from.next(); ok {
+			n, i = s.seek(next)
+		}
+
+		// Step back to the last entry <= from.
+		if i == 0 {
+			if n == 0 {
+				return
+			}
+			n--
+			i = len(s.nodes[n].entries)
+		}
+
+		for ; n >= 0; n-- {
+			entries := s.nodes[n].entries
+			if i < 0 {
+				i = len(entries)
+			}
+			for j := i - 1; j >= 0; j-- {
+				if !yield(entries[j]) {
+					return
+				}
+			}
+			i = -1
+		}
+	}
+}
+
+func (s *stream) find(id StreamID) (streamEntry, bool) {
+	n, i := s.seek(id)
+	if n == len(s.nodes) || i == len(s.nodes[n].entries) || s.nodes[n].entries[i].id != id {
+		return streamEntry{}, false
+	}
+	return s.nodes[n].entries[i], true
+}
+
+func (s *stream) first() (streamEntry, bool) {
+	if s.length == 0 {
+		return streamEntry{}, false
+	}
+	return s.nodes[0].entries[0], true
+}
+
+func (s *stream) last() (streamEntry, bool) {
+	if s.length == 0 {
+		return streamEntry{}, false
+	}
+	entries := s.nodes[len(s.nodes)-1].entries
+	return entries[len(entries)-1], true
+}
+
+func (s *stream) delete(id StreamID) bool {
+	n, i := s.seek(id)
+	if n == len(s.nodes) || i == len(s.nodes[n].entries) || s.nodes[n].entries[i].id != id {
+		return false
+	}
+
+	node := s.nodes[n]
+	node.entries = append(node.entries[:i:i], node.entries[i+1:]...)
+	if len(node.entries) == 0 {
+		s.nodes = append(s.nodes[:n:n], s.nodes[n+1:]...)
+	}
+
+	s.length--
+	s.markDeleted(id)
+	return true
+}
+
+func (s *stream) markDeleted(id StreamID) {
+	if id.Compare(s.maxDeletedID) > 0 {
+		s.maxDeletedID = id
+	}
+}
+
+// trimHead removes entries from the head, at most limit of them, for as long
+// as remove agrees. remove is asked about n entries at a time ending with last:
+// single entries, or whole nodes when wholeNodes is set, which is what
+// approximate trimming does.
+func (s *stream) trimHead(limit int, wholeNodes bool, remove func(last streamEntry, n int) bool) int {
+	var removed int
+	for len(s.nodes) > 0 {
+		node := s.nodes[0]
+		if wholeNodes {
+			n := len(node.entries)
+			if removed+n > limit || !remove(node.entries[n-1], n) {
+				break
+			}
+
+			removed += n
+			s.length -= n
+			s.markDeleted(node.entries[n-1].id)
+			s.nodes = s.nodes[1:]
+			continue
+		}
+
+		var i int
+		for ; i < len(node.entries) && removed < limit && remove(node.entries[i], 1); i++ {
+			removed++
+			s.length--
+			s.markDeleted(node.entries[i].id)
+		}
+
+		if i < len(node.entries) {
+			node.entries = node.entries[i:]
+			break
+		}
+		s.nodes = s.nodes[1:]
+	}
+	return removed
+}
@@ -0,0 +1,609 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// consumerGroup tracks what has been delivered from a stream to a group of
+// consumers. pel is the group wide pending entries list; every entry in it is
+// also referenced from the pending list of the consumer that owns it.
+type consumerGroup struct {
+	name        string
+	lastID      StreamID
+	entriesRead int
+	pel         *pendingList
+	consumers   map[string]*consumer
+}
+
+type consumer struct {
+	name       string
+	seenTime   time.Time
+	activeTime time.Time
+	pending    *pendingList
+}
+
+type pendingEntry struct {
+	id            StreamID
+	consumer      *consumer
+	deliveryTime  time.Time
+	deliveryCount int
+}
+
+// XClaimOptions holds the optional arguments of XCLAIM.
+type XClaimOptions struct {
+	Idle       *time.Duration
+	Time       *time.Time
+	RetryCount *int
+	Force      bool
+	JustID     bool
+	LastID     string
+}
+
+func errNoGroup(key, group string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+func newConsumerGroup(name string, lastID StreamID) *consumerGroup {
+	return &consumerGroup{
+		name:      name,
+		lastID:    lastID,
+		pel:       newPendingList(),
+		consumers: make(map[string]*consumer),
+	}
+}
+
+func (g *consumerGroup) consumer(name string, create bool) *consumer {
+	cons, ok := g.consumers[name]
+	if !ok && create {
+		cons = &consumer{name: name, seenTime: time.Now(), pending: newPendingList()}
+		g.consumers[name] = cons
+	}
+	return cons
+}
+
+// pendingList is a pending entries list. The entries are kept ordered by id,
+// as in the stream nodes, so that XPENDING and XAUTOCLAIM range over them
+// rather than sort them, next to a lookup by id.
+type pendingList struct {
+	byID    map[StreamID]*pendingEntry
+	entries []*pendingEntry
+}
+
+func newPendingList() *pendingList {
+	return &pendingList{byID: make(map[StreamID]*pendingEntry)}
+}
+
+func (l *pendingList) len() int {
+	return len(l.entries)
+}
+
+func (l *pendingList) get(id StreamID) (*pendingEntry, bool) {
+	p, ok := l.byID[id]
+	return p, ok
+}
+
+// search returns the position of the first entry with an id >= id.
+func (l *pendingList) search(id StreamID) (int, bool) {
+	return slices.BinarySearchFunc(l.entries, id, func(p *pendingEntry, id StreamID) int {
+		return p.id.Compare(id)
+	})
+}
+
+// add inserts p, replacing the entry with the same id if any. Entries are
+// mostly delivered in stream order, so they are usually appended.
+func (l *pendingList) add(p *pendingEntry) {
+	if n := len(l.entries); n == 0 || l.entries[n-1].id.Compare(p.id) < 0 {
+		l.entries = append(l.entries, p)
+	} else if idx, found := l.search(p.id); found {
+		l.entries[idx] = p
+	} else {
+		l.entries = slices.Insert(l.entries, idx, p)
+	}
+	l.byID[p.id] = p
+}
+
+func (l *pendingList) remove(id StreamID) {
+	if _, ok := l.byID[id]; !ok {
+		return
+	}
+
+	delete(l.byID, id)
+	if idx, found := l.search(id); found {
+		l.entries = slices.Delete(l.entries, idx, idx+1)
+	}
+}
+
+// from returns the entries with an id >= id, in order. The slice is only
+// valid until the list changes.
+func (l *pendingList) from(id StreamID) []*pendingEntry {
+	idx, _ := l.search(id)
+	return l.entries[idx:]
+}
+
+func (c *cache) group(key, group string) (*consumerGroup, error) {
+	s, ok := c.streamData[key]
+	if !ok {
+		return nil, errNoGroup(key, group)
+	}
+
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, errNoGroup(key, group)
+	}
+	return g, nil
+}
+
+// XGroupCreate creates a group positioned at id. entriesRead, when not nil,
+// is the number of entries the group is taken to have read, -1 if unknown.
+func (c *cache) XGroupCreate(key, group, id string, mkStream bool, entriesRead *int) error {
+	s, ok := c.streamData[key]
+	if !ok {
+		if !mkStream {
+			return ErrXGroupKey
+		}
+		s = newStream()
+		c.streamData[key] = s
+	}
+
+	if _, ok := s.groups[group]; ok {
+		return ErrBusyGroup
+	}
+
+	lastID, err := resolveGroupID(s, id)
+	if err != nil {
+		return err
+	}
+
+	g := newConsumerGroup(group, lastID)
+	g.entriesRead = initialEntriesRead(s, id, entriesRead)
+	s.groups[group] = g
+	c.touch(key)
+	return nil
+}
+
+// XGroupSetID moves a group to id, setting its entries-read counter as
+// XGroupCreate does.
+func (c *cache) XGroupSetID(key, group, id string, entriesRead *int) error {
+	g, err := c.group(key, group)
+	if err != nil {
+		return err
+	}
+
+	s := c.streamData[key]
+	lastID, err := resolveGroupID(s, id)
+	if err != nil {
+		return err
+	}
+
+	g.lastID = lastID
+	g.entriesRead = initialEntriesRead(s, id, entriesRead)
+	return nil
+}
+
+// initialEntriesRead is the entries-read counter of a group positioned at id.
+// Unless the client gave it, it is only known upfront for "$"; otherwise
+// groupEntriesRead works it out. A group cannot have read more entries than
+// were ever added.
+func initialEntriesRead(s *stream, id string, entriesRead *int) int {
+	if entriesRead != nil {
+		return min(*entriesRead, s.entriesAdded)
+	}
+	if id == "$" {
+		return s.entriesAdded
+	}
+	return -1
+}
+
+func resolveGroupID(s *stream, id string) (StreamID, error) {
+	if id == "$" {
+		return s.lastID, nil
+	}
+
+	lastID, ok := parseStreamID(id, 0)
+	if !ok {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	return lastID, nil
+}
+
+func (c *cache) XGroupDestroy(key, group string) (int, error) {
+	s, ok := c.streamData[key]
+	if !ok {
+		return 0, ErrXGroupKey
+	}
+
+	if _, ok := s.groups[group]; !ok {
+		return 0, nil
+	}
+
+	delete(s.groups, group)
+	c.touch(key)
+	return 1, nil
+}
+
+func (c *cache) XGroupCreateConsumer(key, group, name string) (int, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return 0, err
+	}
+
+	if g.consumer(name, false) != nil {
+		return 0, nil
+	}
+
+	g.consumer(name, true)
+	return 1, nil
+}
+
+// XGroupDelConsumer removes a consumer and its pending entries, returning how
+// many entries were pending.
+func (c *cache) XGroupDelConsumer(key, group, name string) (int, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return 0, err
+	}
+
+	cons := g.consumer(name, false)
+	if cons == nil {
+		return 0, nil
+	}
+
+	for _, p := range cons.pending.entries {
+		g.pel.remove(p.id)
+	}
+	delete(g.consumers, name)
+	return cons.pending.len(), nil
+}
+
+// XReadGroup reads for consumer on behalf of group. An id of ">" delivers
+// entries never delivered to the group and adds them to the pending list
+// unless noAck is set; any other id replays the consumer's own pending
+// entries after it. A nil result means there was nothing new to deliver.
+func (c *cache) XReadGroup(group, name string, keys, ids []string, count int, noAck bool) ([]any, error) {
+	groups := make([]*consumerGroup, len(keys))
+	after := make([]StreamID, len(keys))
+	for i, key := range keys {
+		g, err := c.group(key, group)
+		if err != nil {
+			return nil, fmt.Errorf("%w in XREADGROUP with GROUP option", err)
+		}
+		if ids[i] != ">" {
+			id, ok := parseStreamID(ids[i], 0)
+			if !ok {
+				return nil, ErrInvalidStreamID
+			}
+			after[i] = id
+		}
+		groups[i] = g
+	}
+
+	var (
+		res []any
+		now = time.Now()
+	)
+	for i, key := range keys {
+		s, g := c.streamData[key], groups[i]
+		cons := g.consumer(name, true)
+		cons.seenTime = now
+
+		var entries []any
+		if ids[i] == ">" {
+			for e := range s.ascendAfter(g.lastID) {
+				g.lastID = e.id
+				if g.entriesRead >= 0 && !s.hasTombstones() {
+					g.entriesRead++
+				} else {
+					g.entriesRead = -1
+				}
+				cons.activeTime = now
+				if !noAck {
+					g.deliver(cons, e.id, now)
+				}
+
+				entries = append(entries, e.reply())
+				if count > 0 && len(entries) == count {
+					break
+				}
+			}
+
+			if len(entries) == 0 {
+				continue
+			}
+		} else {
+			entries = []any{}
+			for _, p := range cons.pending.from(after[i]) {
+				if p.id.Compare(after[i]) <= 0 {
+					continue
+				}
+
+				p.deliveryTime = now
+				p.deliveryCount++
+				if e, ok := s.find(p.id); ok {
+					entries = append(entries, e.reply())
+				} else {
+					entries = append(entries, []any{p.id.String(), nil})
+				}
+
+				if count > 0 && len(entries) == count {
+					break
+				}
+			}
+		}
+
+		res = append(res, []any{key, entries})
+	}
+
+	return res, nil
+}
+
+// deliver records id as pending for cons, taking it over from whichever
+// consumer had it before.
+func (g *consumerGroup) deliver(cons *consumer, id StreamID, now time.Time) {
+	if p, ok := g.pel.get(id); ok {
+		p.consumer.pending.remove(id)
+	}
+
+	p := &pendingEntry{id: id, consumer: cons, deliveryTime: now, deliveryCount: 1}
+	g.pel.add(p)
+	cons.pending.add(p)
+}
+
+func (c *cache) XAck(key, group string, ids []string) (int, error) {
+	parsed, err := parseStreamIDs(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	g, err := c.group(key, group)
+	if err != nil {
+		return 0, nil
+	}
+
+	var acked int
+	for _, id := range parsed {
+		p, ok := g.pel.get(id)
+		if !ok {
+			continue
+		}
+
+		g.pel.remove(id)
+		p.consumer.pending.remove(id)
+		acked++
+	}
+	return acked, nil
+}
+
+// XPending returns the summary form of XPENDING: the number of pending
+// entries, the smallest and greatest pending id and a per consumer count.
+func (c *cache) XPending(key, group string) ([]any, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.pel.len() == 0 {
+		return []any{0, nil, nil, nil}, nil
+	}
+
+	pending := g.pel.entries
+
+	names := make([]string, 0, len(g.consumers))
+	for name, cons := range g.consumers {
+		if cons.pending.len() > 0 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	perConsumer := make([]any, len(names))
+	for i, name := range names {
+		perConsumer[i] = []any{name, strconv.Itoa(g.consumers[name].pending.len())}
+	}
+
+	return []any{len(pending), pending[0].id.String(), pending[len(pending)-1].id.String(), perConsumer}, nil
+}
+
+// XPendingRange returns the extended form of XPENDING: up to count entries
+// between start and end, optionally filtered by idle time and consumer.
+func (c *cache) XPendingRange(key, group string, minIdle time.Duration, start, end string, count int, name string) ([]any, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to, err := parseStreamRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	pel := g.pel
+	if name != "" {
+		cons := g.consumer(name, false)
+		if cons == nil {
+			return []any{}, nil
+		}
+		pel = cons.pending
+	}
+
+	now := time.Now()
+	res := []any{}
+	for _, p := range pel.from(from) {
+		if len(res) == count || p.id.Compare(to) > 0 {
+			break
+		}
+
+		idle := now.Sub(p.deliveryTime)
+		if idle < minIdle {
+			continue
+		}
+
+		res = append(res, []any{p.id.String(), p.consumer.name, int(idle.Milliseconds()), p.deliveryCount})
+	}
+	return res, nil
+}
+
+// XClaim transfers ownership of pending entries idle for at least minIdle to
+// the named consumer. Entries deleted from the stream are dropped from the
+// pending list instead of being claimed.
+func (c *cache) XClaim(key, group, name string, minIdle time.Duration, ids []string, opts XClaimOptions) ([]any, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseStreamIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.LastID != "" {
+		lastID, ok := parseStreamID(opts.LastID, 0)
+		if !ok {
+			return nil, ErrInvalidStreamID
+		}
+		if lastID.Compare(g.lastID) > 0 {
+			g.lastID = lastID
+		}
+	}
+
+	s := c.streamData[key]
+
+	now := time.Now()
+	deliveryTime := now
+	switch {
+	case opts.Idle != nil:
+		deliveryTime = now.Add(-*opts.Idle)
+	case opts.Time != nil:
+		deliveryTime = *opts.Time
+	}
+
+	cons := g.consumer(name, true)
+	cons.seenTime = now
+
+	res := []any{}
+	for _, id := range parsed {
+		entry, exists := s.find(id)
+
+		p, ok := g.pel.get(id)
+		if !ok {
+			if !opts.Force || !exists {
+				continue
+			}
+
+			p = &pendingEntry{id: id, consumer: cons}
+			g.pel.add(p)
+			cons.pending.add(p)
+		}
+
+		if !exists {
+			g.pel.remove(id)
+			p.consumer.pending.remove(id)
+			continue
+		}
+
+		if minIdle > 0 && now.Sub(p.deliveryTime) < minIdle {
+			continue
+		}
+
+		p.consumer.pending.remove(id)
+		p.consumer = cons
+		cons.pending.add(p)
+		cons.activeTime = now
+
+		p.deliveryTime = deliveryTime
+		if opts.RetryCount != nil {
+			p.deliveryCount = *opts.RetryCount
+		} else if !opts.JustID {
+			p.deliveryCount++
+		}
+
+		if opts.JustID {
+			res = append(res, id.String())
+		} else {
+			res = append(res, entry.reply())
+		}
+	}
+	return res, nil
+}
+
+// XAutoClaim scans the pending list from start and claims up to count entries
+// idle for at least minIdle. It returns the cursor to continue from, the
+// claimed entries and the ids that no longer exist in the stream.
+func (c *cache) XAutoClaim(key, group, name string, minIdle time.Duration, start string, count int, justID bool) ([]any, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	from, ok := parseRangeID(start, 0)
+	if !ok {
+		return nil, ErrInvalidStreamID
+	}
+
+	s := c.streamData[key]
+	now := time.Now()
+	cons := g.consumer(name, true)
+	cons.seenTime = now
+
+	var (
+		claimed  = []any{}
+		deleted  = []any{}
+		cursor   = "0-0"
+		attempts = count * 10
+	)
+	// Entries gone from the stream are dropped from the list after the
+	// scan, which ranges over it.
+	var gone []*pendingEntry
+	for _, p := range g.pel.from(from) {
+		if len(claimed) == count || attempts == 0 {
+			cursor = p.id.String()
+			break
+		}
+		attempts--
+
+		entry, exists := s.find(p.id)
+		if !exists {
+			gone = append(gone, p)
+			deleted = append(deleted, p.id.String())
+			continue
+		}
+
+		if minIdle > 0 && now.Sub(p.deliveryTime) < minIdle {
+			continue
+		}
+
+		p.consumer.pending.remove(p.id)
+		p.consumer = cons
+		cons.pending.add(p)
+		cons.activeTime = now
+
+		p.deliveryTime = now
+		if !justID {
+			p.deliveryCount++
+			claimed = append(claimed, entry.reply())
+		} else {
+			claimed = append(claimed, p.id.String())
+		}
+	}
+
+	for _, p := range gone {
+		g.pel.remove(p.id)
+		p.consumer.pending.remove(p.id)
+	}
+
+	return []any{cursor, claimed, deleted}, nil
+}
+
+func parseStreamIDs(ids []string) ([]StreamID, error) {
+	parsed := make([]StreamID, len(ids))
+	for i, id := range ids {
+		var ok bool
+		if parsed[i], ok = parseStreamID(id, 0); !ok {
+			return nil, ErrInvalidStreamID
+		}
+	}
+	return parsed, nil
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGroupStream(t *testing.T) Cache {
+	c := New()
+	c.XAdd("s", "1-1", []any{"a", "1"})
+	c.XAdd("s", "2-1", []any{"b", "2"})
+	require.NoError(t, c.XGroupCreate("s", "g", "0", false, nil))
+	return c
+}
+
+func TestXGroupCreateRequiresStreamUnlessMkStream(t *testing.T) {
+	c := New()
+
+	assert.ErrorIs(t, c.XGroupCreate("missing", "g", "$", false, nil), ErrXGroupKey)
+	require.NoError(t, c.XGroupCreate("missing", "g", "$", true, nil))
+	assert.Equal(t, "stream", c.Type("missing"))
+	assert.ErrorIs(t, c.XGroupCreate("missing", "g", "$", true, nil), ErrBusyGroup)
+}
+
+func TestXReadGroupDeliversEachEntryOnce(t *testing.T) {
+	c := newGroupStream(t)
+
+	r, err := c.XReadGroup("g", "alice", []string{"s"}, []string{">"}, 1, false)
+	require.NoError(t, err)
+	assert.Equal(t, []any{[]any{"s", []any{[2]any{"1-1", []any{"a", "1"}}}}}, r)
+
+	r, err = c.XReadGroup("g", "bob", []string{"s"}, []string{">"}, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, []any{[]any{"s", []any{[2]any{"2-1", []any{"b", "2"}}}}}, r)
+
+	r, err = c.XReadGroup("g", "bob", []string{"s"}, []string{">"}, 0, false)
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	summary, err := c.XPending("s", "g")
+	require.NoError(t, err)
+	assert.Equal(t, []any{2, "1-1", "2-1", []any{[]any{"alice", "1"}, []any{"bob", "1"}}}, summary)
+}
+
+func TestXReadGroupHistoryIncrementsDeliveryCount(t *testing.T) {
+	c := newGroupStream(t)
+	_, err := c.XReadGroup("g", "alice", []string{"s"}, []string{">"}, 0, false)
+	require.NoError(t, err)
+
+	_, err = c.XReadGroup("g", "alice", []string{"s"}, []string{"0"}, 0, false)
+	require.NoError(t, err)
+
+	r, err := c.XPendingRange("s", "g", 0, "-", "+", 10, "alice")
+	require.NoError(t, err)
+	require.Len(t, r, 2)
+	assert.Equal(t, 2, r[0].([]any)[3])
+}
+
+func TestXAckRemovesFromPendingList(t *testing.T) {
+	c := newGroupStream(t)
+	_, err := c.XReadGroup("g", "alice", []string{"s"}, []string{">"}, 0, false)
+	require.NoError(t, err)
+
+	n, err := c.XAck("s", "g", []string{"1-1", "9-9"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	summary, err := c.XPending("s", "g")
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary[0])
+}
+
+func TestXClaimRespectsMinIdleTime(t *testing.T) {
+	c := newGroupStream(t)
+	_, err := c.XReadGroup("g", "alice", []string{"s"}, []string{">"}, 0, false)
+	require.NoError(t, err)
+
+	r, err := c.XClaim("s", "g", "bob", time.Hour, []string{"1-1"}, XClaimOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, r)
+
+	r, err = c.XClaim("s", "g", "bob", 0, []string{"1-1"}, XClaimOptions{JustID: true})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"1-1"}, r)
+
+	pending, err := c.XPendingRange("s", "g", 0, "-", "+", 10, "bob")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].([]any)[3])
+}
+
+func TestXAutoClaimReturnsCursor(t *testing.T) {
+	c := newGroupStream(t)
+	_, err := c.XReadGroup("g", "alice", []string{"s"}, []string{">"}, 0, false)
+	require.NoError(t, err)
+
+	r, err := c.XAutoClaim("s", "g", "bob", 0, "0-0", 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"2-1", []any{"1-1"}, []any{}}, r)
+
+	r, err = c.XAutoClaim("s", "g", "bob", 0, "2-1", 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"0-0", []any{"2-1"}, []any{}}, r)
+}
+
+func TestPendingListStaysOrdered(t *testing.T) {
+	l := newPendingList()
+	for _, id := range []StreamID{{3, 0}, {1, 0}, {5, 0}, {2, 0}, {1, 0}} {
+		l.add(&pendingEntry{id: id})
+	}
+	l.remove(StreamID{3, 0})
+	l.remove(StreamID{9, 0})
+
+	var ids []string
+	for _, p := range l.from(StreamID{2, 0}) {
+		ids = append(ids, p.id.String())
+	}
+	assert.Equal(t, []string{"2-0", "5-0"}, ids)
+	assert.Equal(t, 3, l.len())
+}
+
+func TestXGroupSetIDEntriesRead(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 5)
+	read := 3
+	require.NoError(t, c.XGroupCreate("s", "g", "0", false, &read))
+
+	lag := func() []any {
+		t.Helper()
+
+		groups, err := c.XInfoGroups("s")
+		require.NoError(t, err)
+		g := groups[0].([]any)
+		return g[len(g)-4:]
+	}
+	assert.Equal(t, []any{"entries-read", 3, "lag", 2}, lag())
+
+	read = 99
+	require.NoError(t, c.XGroupSetID("s", "g", "5-0", &read))
+	assert.Equal(t, []any{"entries-read", 5, "lag", 0}, lag(), "capped at the entries added")
+
+	read = -1
+	require.NoError(t, c.XGroupSetID("s", "g", "2-0", &read))
+	assert.Equal(t, []any{"entries-read", 2, "lag", 3}, lag(), "worked out from the position")
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"slices"
+	"time"
+)
+
+// XInfoStream returns the XINFO STREAM fields as a flat key/value list.
+func (c *cache) XInfoStream(key string) ([]any, error) {
+	s, ok := c.streamData[key]
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+
+	var first, last any
+	var firstID StreamID
+	if e, ok := s.first(); ok {
+		first, firstID = e.reply(), e.id
+	}
+	if e, ok := s.last(); ok {
+		last = e.reply()
+	}
+
+	return []any{
+		"length", s.length,
+		"radix-tree-keys", len(s.nodes),
+		"radix-tree-nodes", len(s.nodes),
+		"last-generated-id", s.lastID.String(),
+		"max-deleted-entry-id", s.maxDeletedID.String(),
+		"entries-added", s.entriesAdded,
+		"recorded-first-entry-id", firstID.String(),
+		"groups", len(s.groups),
+		"first-entry", first,
+		"last-entry", last,
+	}, nil
+}
+
+// XInfoGroups returns one key/value list per consumer group, ordered by name.
+func (c *cache) XInfoGroups(key string) ([]any, error) {
+	s, ok := c.streamData[key]
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+
+	groups := s.groups
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	res := make([]any, len(names))
+	for i, name := range names {
+		g := groups[name]
+
+		var entriesRead, lag any
+		if read := s.groupEntriesRead(g); read >= 0 {
+			entriesRead = read
+			lag = s.entriesAdded - read
+		}
+
+		res[i] = []any{
+			"name", g.name,
+			"consumers", len(g.consumers),
+			"pending", g.pel.len(),
+			"last-delivered-id", g.lastID.String(),
+			"entries-read", entriesRead,
+			"lag", lag,
+		}
+	}
+	return res, nil
+}
+
+// XInfoConsumers returns one key/value list per consumer of group, ordered by
+// name. Idle and inactive times are in milliseconds; inactive is -1 for a
+// consumer that never read anything.
+func (c *cache) XInfoConsumers(key, group string) ([]any, error) {
+	g, err := c.group(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(g.consumers))
+	for name := range g.consumers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	now := time.Now()
+	res := make([]any, len(names))
+	for i, name := range names {
+		cons := g.consumers[name]
+
+		inactive := -1
+		if !cons.activeTime.IsZero() {
+			inactive = int(now.Sub(cons.activeTime).Milliseconds())
+		}
+
+		res[i] = []any{
+			"name", cons.name,
+			"pending", cons.pending.len(),
+			"idle", int(now.Sub(cons.seenTime).Milliseconds()),
+			"inactive", inactive,
+		}
+	}
+	return res, nil
+}
+
+// groupEntriesRead returns how many entries of the stream the group has read,
+// or -1 when deletions make that impossible to know.
+func (s *stream) groupEntriesRead(g *consumerGroup) int {
+	if g.entriesRead >= 0 {
+		return g.entriesRead
+	}
+
+	if s.entriesAdded == 0 || g.lastID.Compare(s.lastID) >= 0 {
+		return s.entriesAdded
+	}
+
+	if s.hasTombstones() {
+		return -1
+	}
+
+	// Every entry still in the stream was added after the ones trimmed from
+	// the head, so counting up to the group's position is exact.
+	read := s.entriesAdded - s.length
+	for e := range s.ascend(StreamID{}) {
+		if e.id.Compare(g.lastID) > 0 {
+			break
+		}
+		read++
+	}
+	return read
+}
+
+// hasTombstones reports whether entries were deleted from the middle of the
+// stream rather than trimmed from its head.
+func (s *stream) hasTombstones() bool {
+	first, ok := s.first()
+	return ok && s.maxDeletedID.Compare(first.id) > 0
+}
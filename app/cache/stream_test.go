@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamIDsCompareNumerically(t *testing.T) {
+	a, err := ParseStreamID("9-0")
+	require.NoError(t, err)
+	b, err := ParseStreamID("10")
+	require.NoError(t, err)
+
+	assert.Equal(t, -1, a.Compare(b))
+	assert.Equal(t, "10-0", b.String())
+
+	_, err = ParseStreamID("1-x")
+	assert.ErrorIs(t, err, ErrInvalidStreamID)
+}
+
+func TestXAddGeneratesIncreasingIDs(t *testing.T) {
+	c := New()
+
+	_, err := c.XAdd("s", "0-0", []any{"a", "1"})
+	assert.ErrorIs(t, err, ErrStreamIDZero)
+
+	id, err := c.XAdd("s", "0-*", []any{"a", "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "0-1", id)
+
+	id, err = c.XAdd("s", "9-*", []any{"a", "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "9-0", id)
+
+	id, err = c.XAdd("s", "9-*", []any{"a", "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "9-1", id)
+
+	_, err = c.XAdd("s", "8-5", []any{"a", "1"})
+	assert.ErrorIs(t, err, ErrStreamIDTooSmall)
+
+	id, err = c.XAdd("s", "10-0", []any{"a", "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "10-0", id)
+
+	id, err = c.XAdd("s", "*", []any{"a", "1"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "", id)
+}
+
+func TestXRangeSpansNodes(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 3*streamNodeEntries)
+
+	r, err := c.XRange("s", "99", "102", 0)
+	require.NoError(t, err)
+	ids := make([]string, len(r))
+	for i, e := range r {
+		ids[i] = e.([2]any)[0].(string)
+	}
+	assert.Equal(t, []string{"99-0", "100-0", "101-0", "102-0"}, ids)
+
+	r, err = c.XRevRange("s", "201", "-", 3)
+	require.NoError(t, err)
+	require.Len(t, r, 3)
+	assert.Equal(t, "201-0", r[0].([2]any)[0])
+	assert.Equal(t, "199-0", r[2].([2]any)[0])
+
+	_, err = c.XRange("s", "x", "+", 0)
+	assert.ErrorIs(t, err, ErrInvalidStreamID)
+}
+
+func TestXRangeExclusiveBounds(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 5)
+	c.XAdd("s", "5-1", []any{"i", "5.1"})
+
+	ids := func(r []any) []string {
+		res := make([]string, len(r))
+		for i, e := range r {
+			res[i] = e.([2]any)[0].(string)
+		}
+		return res
+	}
+
+	r, err := c.XRange("s", "(2-0", "(5-1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3-0", "4-0", "5-0"}, ids(r))
+
+	// Without a sequence, a bound excludes the id it stands for: the first of
+	// the millisecond for a start, the last for an end.
+	r, err = c.XRange("s", "(4", "+", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5-0", "5-1"}, ids(r))
+	r, err = c.XRevRange("s", "(5", "-", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5-1", "5-0"}, ids(r))
+
+	_, err = c.XRange("s", "(18446744073709551615-18446744073709551615", "+", 0)
+	assert.ErrorIs(t, err, ErrIntervalStart)
+	_, err = c.XRange("s", "-", "(0-0", 0)
+	assert.ErrorIs(t, err, ErrIntervalEnd)
+	_, err = c.XRange("s", "(-", "+", 0)
+	assert.ErrorIs(t, err, ErrInvalidStreamID)
+}
+
+func TestXDelDropsEmptiedNodes(t *testing.T) {
+	c := New()
+	fillStream(c, "s", streamNodeEntries+1)
+
+	ids := make([]string, streamNodeEntries)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i+1) + "-0"
+	}
+	n, err := c.XDel("s", ids)
+	require.NoError(t, err)
+	assert.Equal(t, streamNodeEntries, n)
+
+	r := c.XRead([]string{"s"}, []string{"0"}, 0)
+	assert.Equal(t, []any{[]any{"s", []any{[2]any{"101-0", []any{"i", "101"}}}}}, r)
+
+	info, err := c.XInfoStream("s")
+	require.NoError(t, err)
+	assert.Equal(t, 1, info[3])
+}
@@ -0,0 +1,94 @@
+package cache
+
+import "math"
+
+const (
+	TrimMaxLen = "maxlen"
+	TrimMinID  = "minid"
+)
+
+// XTrimOptions is the trimming clause shared by XADD and XTRIM.
+type XTrimOptions struct {
+	Strategy string // maxlen or minid
+	MaxLen   int
+	MinID    string
+	Approx   bool
+	Limit    int // 0 means the default for approximate trimming, < 0 no limit
+}
+
+func (c *cache) streamLastID(key string) string {
+	if s, ok := c.streamData[key]; ok {
+		return s.lastID.String()
+	}
+	return "0-0"
+}
+
+func (c *cache) XLen(key string) int {
+	if s, ok := c.streamData[key]; ok {
+		return s.length
+	}
+	return 0
+}
+
+func (c *cache) XDel(key string, ids []string) (int, error) {
+	parsed, err := parseStreamIDs(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	s, ok := c.streamData[key]
+	if !ok {
+		return 0, nil
+	}
+
+	var deleted int
+	for _, id := range parsed {
+		if s.delete(id) {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		c.touch(key)
+	}
+	return deleted, nil
+}
+
+// XTrim evicts entries from the head of the stream. With Approx only whole
+// nodes are removed, so the stream may keep more entries than asked for.
+func (c *cache) XTrim(key string, opts XTrimOptions) (int, error) {
+	var minID StreamID
+	if opts.Strategy == TrimMinID {
+		var ok bool
+		if minID, ok = parseStreamID(opts.MinID, 0); !ok {
+			return 0, ErrInvalidStreamID
+		}
+	}
+
+	s, ok := c.streamData[key]
+	if !ok {
+		return 0, nil
+	}
+
+	limit := math.MaxInt
+	if opts.Approx {
+		switch {
+		case opts.Limit == 0:
+			limit = streamNodeEntries * 100
+		case opts.Limit > 0:
+			limit = opts.Limit
+		}
+	}
+
+	removed := s.trimHead(limit, opts.Approx, func(last streamEntry, n int) bool {
+		if opts.Strategy == TrimMaxLen {
+			return s.length-n >= opts.MaxLen
+		}
+		return last.id.Compare(minID) < 0
+	})
+
+	if removed > 0 {
+		c.touch(key)
+	}
+	return removed, nil
+}
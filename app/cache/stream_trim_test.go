@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fillStream adds entries 1-0 to n-0.
+func fillStream(c Cache, key string, n int) {
+	for i := 1; i <= n; i++ {
+		c.XAdd(key, strconv.Itoa(i)+"-0", []any{"i", strconv.Itoa(i)})
+	}
+}
+
+func TestXTrimMaxLenExactAndApproximate(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 250)
+
+	n, err := c.XTrim("s", XTrimOptions{Strategy: TrimMaxLen, MaxLen: 120, Approx: true})
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, 150, c.XLen("s"))
+
+	n, err = c.XTrim("s", XTrimOptions{Strategy: TrimMaxLen, MaxLen: 120})
+	require.NoError(t, err)
+	assert.Equal(t, 30, n)
+	assert.Equal(t, 120, c.XLen("s"))
+}
+
+func TestXTrimMinID(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 10)
+
+	n, err := c.XTrim("s", XTrimOptions{Strategy: TrimMinID, MinID: "4"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	r, err := c.XRange("s", "-", "+", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []any{[2]any{"4-0", []any{"i", "4"}}}, r)
+}
+
+func TestXDelKeepsLastGeneratedID(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 3)
+
+	n, err := c.XDel("s", []string{"3-0", "7-0"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = c.XAdd("s", "3-0", []any{"a", "b"})
+	assert.ErrorIs(t, err, ErrStreamIDTooSmall)
+
+	info, err := c.XInfoStream("s")
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		"length", 2,
+		"radix-tree-keys", 1,
+		"radix-tree-nodes", 1,
+		"last-generated-id", "3-0",
+		"max-deleted-entry-id", "3-0",
+		"entries-added", 3,
+		"recorded-first-entry-id", "1-0",
+		"groups", 0,
+		"first-entry", [2]any{"1-0", []any{"i", "1"}},
+		"last-entry", [2]any{"2-0", []any{"i", "2"}},
+	}, info)
+}
+
+func TestXRevRangeHonoursCount(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 5)
+
+	r, err := c.XRevRange("s", "+", "-", 2)
+	require.NoError(t, err)
+	require.Len(t, r, 2)
+	assert.Equal(t, "5-0", r[0].([2]any)[0])
+	assert.Equal(t, "4-0", r[1].([2]any)[0])
+}
+
+func TestXInfoGroupsReportsLag(t *testing.T) {
+	c := New()
+	fillStream(c, "s", 5)
+	require.NoError(t, c.XGroupCreate("s", "g", "0", false, nil))
+
+	_, err := c.XReadGroup("g", "c", []string{"s"}, []string{">"}, 2, false)
+	require.NoError(t, err)
+
+	groups, err := c.XInfoGroups("s")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []any{
+		"name", "g",
+		"consumers", 1,
+		"pending", 2,
+		"last-delivered-id", "2-0",
+		"entries-read", 2,
+		"lag", 3,
+	}, groups[0])
+}
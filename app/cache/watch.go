@@ -0,0 +1,50 @@
+package cache
+
+// watchedKey is the modification counter of a key at least one client
+// watches. Keys nobody watches are not tracked at all.
+type watchedKey struct {
+	refs    int
+	version uint64
+}
+
+// Watch starts tracking modifications of key and returns its current version.
+// Every Watch must be paired with an Unwatch.
+func (c *cache) Watch(key string) uint64 {
+	w, ok := c.watched[key]
+	if !ok {
+		w = &watchedKey{version: c.version}
+		c.watched[key] = w
+	}
+	w.refs++
+	return w.version
+}
+
+func (c *cache) Unwatch(key string) {
+	w, ok := c.watched[key]
+	if !ok {
+		return
+	}
+
+	if w.refs--; w.refs == 0 {
+		delete(c.watched, key)
+	}
+}
+
+// KeyVersion returns a number that changes whenever a watched key is written
+// to, deleted or expires.
+func (c *cache) KeyVersion(key string) uint64 {
+	if w, ok := c.watched[key]; ok {
+		return w.version
+	}
+	return 0
+}
+
+// touch records a modification of key for the clients watching it and for
+// memory accounting.
+func (c *cache) touch(key string) {
+	c.dirty[key] = struct{}{}
+	if w, ok := c.watched[key]; ok {
+		c.version++
+		w.version = c.version
+	}
+}
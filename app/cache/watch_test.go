@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyVersionChangesOnWrite(t *testing.T) {
+	c := New()
+	v := c.Watch("k")
+
+	c.Get("k")
+	assert.Equal(t, v, c.KeyVersion("k"))
+
+	c.Set("k", "1")
+	assert.NotEqual(t, v, c.KeyVersion("k"))
+
+	v = c.KeyVersion("k")
+	c.Del("k")
+	assert.NotEqual(t, v, c.KeyVersion("k"))
+}
+
+func TestKeyVersionTracksEveryType(t *testing.T) {
+	for name, write := range map[string]func(c Cache){
+		"list":   func(c Cache) { c.RPush("k", []any{"a"}) },
+		"stream": func(c Cache) { c.XAdd("k", "*", []any{"a", "1"}) },
+		"bitmap": func(c Cache) { c.SetBit("k", 1, 1) },
+		"hll":    func(c Cache) { c.PFAdd("k", []string{"a"}) },
+		"geo":    func(c Cache) { c.GeoAdd("k", []GeoMember{{Name: "a", Lon: 1, Lat: 1}}, "", false) },
+	} {
+		c := New()
+		v := c.Watch("k")
+		write(c)
+		assert.NotEqual(t, v, c.KeyVersion("k"), name)
+	}
+}
+
+func TestUnwatchStopsTracking(t *testing.T) {
+	c := New()
+	c.Watch("k")
+	c.Watch("k")
+
+	c.Unwatch("k")
+	c.Set("k", "1")
+	assert.NotZero(t, c.KeyVersion("k"))
+
+	c.Unwatch("k")
+	assert.Zero(t, c.KeyVersion("k"))
+}
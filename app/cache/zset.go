@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+)
+
+type zsetEntry struct {
+	member string
+	score  float64
+}
+
+// sortedSet keeps members ordered by score, then member, next to a score
+// lookup map, which is enough for the range scans geo commands need.
+type sortedSet struct {
+	scores  map[string]float64
+	entries []zsetEntry
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{scores: make(map[string]float64)}
+}
+
+func compareZsetEntries(a, b zsetEntry) int {
+	if c := cmp.Compare(a.score, b.score); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.member, b.member)
+}
+
+// add inserts member or moves it to score. It reports whether member is new
+// and whether an existing member changed score.
+func (z *sortedSet) add(member string, score float64) (bool, bool) {
+	old, exists := z.scores[member]
+	if exists {
+		if old == score {
+			return false, false
+		}
+		z.remove(member)
+	}
+
+	e := zsetEntry{member: member, score: score}
+	idx, _ := slices.BinarySearchFunc(z.entries, e, compareZsetEntries)
+	z.entries = slices.Insert(z.entries, idx, e)
+	z.scores[member] = score
+
+	return !exists, exists
+}
+
+func (z *sortedSet) remove(member string) bool {
+	score, ok := z.scores[member]
+	if !ok {
+		return false
+	}
+
+	idx, found := slices.BinarySearchFunc(z.entries, zsetEntry{member: member, score: score}, compareZsetEntries)
+	if found {
+		z.entries = slices.Delete(z.entries, idx, idx+1)
+	}
+	delete(z.scores, member)
+	return true
+}
+
+func (z *sortedSet) score(member string) (float64, bool) {
+	s, ok := z.scores[member]
+	return s, ok
+}
+
+func (z *sortedSet) len() int {
+	return len(z.entries)
+}
@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+func (c *Client) handleSetBit(args []string) (string, error) {
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		return protocol.ErrorString(cache.ErrBitOffset.Error()), nil
+	}
+
+	bit, err := strconv.Atoi(args[2])
+	if err != nil || (bit != 0 && bit != 1) {
+		return protocol.ErrorString("ERR bit is not an integer or out of range"), nil
+	}
+
+	r, err := c.keyspace().SetBit(args[0], offset, bit)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleGetBit(args []string) (string, error) {
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		return protocol.ErrorString(cache.ErrBitOffset.Error()), nil
+	}
+
+	r, err := c.keyspace().GetBit(args[0], offset)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleBitCount(args []string) (string, error) {
+	start, end, unit := 0, -1, cache.BitUnitByte
+	switch len(args) {
+	case 1:
+	case 3, 4:
+		var err error
+		if start, err = strconv.Atoi(args[1]); err != nil {
+			return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+		}
+		if end, err = strconv.Atoi(args[2]); err != nil {
+			return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+		}
+		if len(args) == 4 {
+			if unit, err = parseBitUnit(args[3]); err != nil {
+				return protocol.ErrorString(err.Error()), nil
+			}
+		}
+	default:
+		return protocol.ErrorString("ERR syntax error"), nil
+	}
+
+	r, err := c.keyspace().BitCount(args[0], start, end, unit)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleBitPos(args []string) (string, error) {
+	if len(args) < 2 || len(args) > 5 {
+		return protocol.ErrorString("ERR wrong number of arguments for 'bitpos' command"), nil
+	}
+
+	bit, err := strconv.Atoi(args[1])
+	if err != nil || (bit != 0 && bit != 1) {
+		return protocol.ErrorString("ERR The bit argument must be 1 or 0."), nil
+	}
+
+	var (
+		start int
+		end   *int
+		unit  = cache.BitUnitByte
+	)
+
+	if len(args) > 2 {
+		if start, err = strconv.Atoi(args[2]); err != nil {
+			return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+		}
+	}
+
+	if len(args) > 3 {
+		e, err := strconv.Atoi(args[3])
+		if err != nil {
+			return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+		}
+		end = &e
+	}
+
+	if len(args) > 4 {
+		if unit, err = parseBitUnit(args[4]); err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+	}
+
+	r, err := c.keyspace().BitPos(args[0], bit, start, end, unit)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleBitOp(args []string) (string, error) {
+	op := strings.ToLower(args[0])
+	switch op {
+	case "and", "or", "xor", "not":
+	default:
+		return protocol.ErrorString("ERR syntax error"), nil
+	}
+
+	r, err := c.keyspace().BitOp(op, args[1], args[2:])
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleBitField(args []string) (string, error) {
+	return c.bitField("bitfield", args, false)
+}
+
+func (c *Client) handleBitFieldRO(args []string) (string, error) {
+	return c.bitField("bitfield_ro", args, true)
+}
+
+func (c *Client) bitField(name string, args []string, readOnly bool) (string, error) {
+	if len(args) < 1 {
+		return protocol.ErrorString("ERR wrong number of arguments for '" + name + "' command"), nil
+	}
+
+	overflow := cache.OverflowWrap
+	var ops []cache.BitFieldOp
+	for i := 1; i < len(args); i++ {
+		sub := strings.ToLower(args[i])
+		switch sub {
+		case "overflow":
+			if readOnly || i+1 >= len(args) {
+				return protocol.ErrorString("ERR syntax error"), nil
+			}
+
+			overflow = strings.ToLower(args[i+1])
+			switch overflow {
+			case cache.OverflowWrap, cache.OverflowSat, cache.OverflowFail:
+			default:
+				return protocol.ErrorString("ERR Invalid OVERFLOW type specified"), nil
+			}
+			i++
+		case "get", "set", "incrby":
+			need := 2
+			if sub != "get" {
+				need = 3
+			}
+			if readOnly && sub != "get" {
+				return protocol.ErrorString("ERR BITFIELD_RO only supports the GET subcommand"), nil
+			}
+			if i+need >= len(args) {
+				return protocol.ErrorString("ERR syntax error"), nil
+			}
+
+			signed, width, err := parseBitFieldType(args[i+1])
+			if err != nil {
+				return protocol.ErrorString(err.Error()), nil
+			}
+
+			offset, err := parseBitFieldOffset(args[i+2], width)
+			if err != nil {
+				return protocol.ErrorString(err.Error()), nil
+			}
+
+			op := cache.BitFieldOp{Kind: sub, Signed: signed, Bits: width, Offset: offset, Overflow: overflow}
+			if sub != "get" {
+				if op.Value, err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+					return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+				}
+			}
+
+			ops = append(ops, op)
+			i += need
+		default:
+			return protocol.ErrorString("ERR syntax error"), nil
+		}
+	}
+
+	r, err := c.keyspace().BitField(args[0], ops)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return c.reply(r), nil
+}
+
+func parseBitUnit(arg string) (string, error) {
+	switch strings.ToLower(arg) {
+	case cache.BitUnitByte:
+		return cache.BitUnitByte, nil
+	case cache.BitUnitBit:
+		return cache.BitUnitBit, nil
+	default:
+		return "", errSyntax
+	}
+}
+
+func parseBitFieldType(arg string) (bool, int, error) {
+	if len(arg) < 2 {
+		return false, 0, errBitFieldType
+	}
+
+	var signed bool
+	switch arg[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, errBitFieldType
+	}
+
+	width, err := strconv.Atoi(arg[1:])
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, errBitFieldType
+	}
+
+	return signed, width, nil
+}
+
+// parseBitFieldOffset accepts a plain bit offset or a #N offset, which is
+// multiplied by the field width.
+func parseBitFieldOffset(arg string, width int) (int, error) {
+	multiply := strings.HasPrefix(arg, "#")
+	offset, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil || offset < 0 {
+		return 0, cache.ErrBitOffset
+	}
+
+	if multiply {
+		offset *= width
+	}
+
+	if offset+width-1 > 1<<32-1 {
+		return 0, cache.ErrBitOffset
+	}
+	return offset, nil
+}
@@ -0,0 +1,394 @@
+package executor
+
+import (
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// Executor is the state shared by the clients of one server: the databases,
+// the connected clients and their Pub/Sub subscriptions. Executors are
+// independent of each other, so several servers can run in one process.
+type Executor struct {
+	config Config
+
+	// mu serialises command execution across the executor's clients, which
+	// is what makes EXEC atomic. Blocking commands release it while they
+	// wait. It guards the rest of the executor and its clients, unless noted
+	// otherwise.
+	mu sync.Mutex
+	// inExec is set while EXEC runs its queue, so that blocking commands
+	// return immediately.
+	inExec bool
+
+	// dbs are the numbered databases clients SELECT.
+	dbs []cache.Cache
+
+	// clients holds every connected client by id.
+	clients map[int64]*Client
+	// lastClientID is the id of the latest client. It is atomic, so clients
+	// get their id without the lock.
+	lastClientID atomic.Int64
+
+	// channels, patterns and shardChannels are the Pub/Sub registries. Shard
+	// channels are a namespace of their own: PUBLISH does not reach them, nor
+	// SPUBLISH regular channels.
+	channels      subscriptions
+	patterns      subscriptions
+	shardChannels shardSubscriptions
+
+	pause clientPause
+
+	// shutdown is closed by SHUTDOWN, once shuttingDown is set.
+	shutdown     chan struct{}
+	shuttingDown bool
+
+	evictedKeys int
+	// peakMemory is the highest used memory seen so far.
+	peakMemory int
+
+	stats   serverStats
+	slowLog slowLog
+	latency latencyMonitor
+
+	commands *commandTable
+}
+
+// Config holds the settings of an Executor.
+type Config struct {
+	// MaxMemory is the memory the databases may use, in bytes, before keys
+	// are evicted according to MaxMemoryPolicy. 0 means no limit.
+	MaxMemory       int
+	MaxMemoryPolicy EvictionPolicy
+	// MaxMemorySamples is how many keys of each database are sampled to
+	// pick one to evict, 5 if 0.
+	MaxMemorySamples int
+	// MaxMemoryClients is the memory the replies waiting to be read by all
+	// clients may use before the clients holding the most are evicted. 0
+	// means no limit.
+	MaxMemoryClients int
+
+	// SlowLogSlowerThan is how long a command must run to be logged in the
+	// slow log, DefaultSlowLogSlowerThan if 0. A negative value turns the
+	// slow log off.
+	SlowLogSlowerThan time.Duration
+	// SlowLogMaxLen is how many commands the slow log keeps,
+	// DefaultSlowLogMaxLen if 0.
+	SlowLogMaxLen int
+	// LatencyMonitorThreshold is the latency from which events are recorded
+	// by the latency monitor, which is off when it is 0.
+	LatencyMonitorThreshold time.Duration
+
+	// NormalOutputLimit bounds the replies waiting to be read by clients
+	// that are not subscribed to anything. There is no limit by default.
+	NormalOutputLimit OutputBufferLimit
+	// PubSubOutputLimit is the limit of subscribed clients,
+	// DefaultPubSubOutputLimit if zero.
+	PubSubOutputLimit OutputBufferLimit
+}
+
+// New returns an executor running commands against dbs, which clients start
+// with the first of.
+func New(dbs []cache.Cache, config Config) *Executor {
+	if config.MaxMemorySamples <= 0 {
+		config.MaxMemorySamples = 5
+	}
+	if config.SlowLogSlowerThan == 0 {
+		config.SlowLogSlowerThan = DefaultSlowLogSlowerThan
+	}
+	if config.SlowLogMaxLen <= 0 {
+		config.SlowLogMaxLen = DefaultSlowLogMaxLen
+	}
+	if config.PubSubOutputLimit == (OutputBufferLimit{}) {
+		config.PubSubOutputLimit = DefaultPubSubOutputLimit
+	}
+
+	return &Executor{
+		config:        config,
+		dbs:           dbs,
+		clients:       make(map[int64]*Client),
+		channels:      make(subscriptions),
+		patterns:      make(subscriptions),
+		shardChannels: make(shardSubscriptions),
+		shutdown:      make(chan struct{}),
+		stats:         newServerStats(),
+		latency:       make(latencyMonitor),
+		commands:      newCommandTable(),
+	}
+}
+
+// Close closes every client, waiting for the command running to finish.
+// Clients blocked in a command are woken up and close too.
+func (e *Executor) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, c := range e.clients {
+		c.close()
+	}
+}
+
+// Client is the state the executor keeps for one connection.
+type Client struct {
+	ex *Executor
+
+	id    int64
+	name  string
+	conn  net.Conn
+	addr  string
+	laddr string
+
+	db       int
+	protocol int
+
+	created         time.Time
+	lastInteraction time.Time
+	lastCmd         string
+
+	blocked         bool
+	noEvict         bool
+	closed          bool
+	closeAfterReply bool
+
+	// blockedFor is how long the running command has waited in await, which
+	// does not count towards its duration in the slow log.
+	blockedFor time.Duration
+
+	tx transaction
+
+	// channels, patterns and shardChannels are the client's Pub/Sub
+	// subscriptions.
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+
+	// out buffers the replies until Flush. It is guarded by the executor
+	// lock, as publishers write messages into it too. It flushes into output,
+	// whose writer goroutine sends them.
+	out    *protocol.Writer
+	output *output
+	// softLimitSince is when the client went over the soft output limit.
+	softLimitSince time.Time
+
+	// done is closed when the client is, to wake up a blocked command.
+	done chan struct{}
+}
+
+// NewClient registers a client for conn. The client owns the connection from
+// then on and closes it in Close.
+func (e *Executor) NewClient(conn net.Conn) *Client {
+	now := time.Now()
+	output := newOutput()
+	c := &Client{
+		ex:              e,
+		id:              e.lastClientID.Add(1),
+		conn:            conn,
+		out:             protocol.NewWriter(output),
+		output:          output,
+		addr:            conn.RemoteAddr().String(),
+		laddr:           conn.LocalAddr().String(),
+		protocol:        protocol.RESP2,
+		created:         now,
+		lastInteraction: now,
+		done:            make(chan struct{}),
+	}
+	go output.run(conn, c.done)
+
+	e.mu.Lock()
+	e.clients[c.id] = c
+	e.stats.connections++
+	e.mu.Unlock()
+
+	return c
+}
+
+// ID is the client's unique id, as reported by CLIENT ID.
+func (c *Client) ID() int64 {
+	return c.id
+}
+
+// Name is the name set with CLIENT SETNAME or HELLO. Like Protocol, it is
+// meant to be read by registered commands while they run.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Protocol is the RESP version negotiated with HELLO.
+func (c *Client) Protocol() int {
+	return c.protocol
+}
+
+// keyspace is the database the client has selected.
+func (c *Client) keyspace() cache.Cache {
+	return c.ex.dbs[c.db]
+}
+
+// Execute runs one request on behalf of the client and buffers the reply
+// until Flush, so that the replies to a pipeline can be written at once.
+func (c *Client) Execute(resp protocol.RESP) error {
+	name, args := parseCommand(resp)
+
+	if name != "client" {
+		c.ex.pause.wait(c.isWrite(name))
+	}
+
+	c.ex.mu.Lock()
+	defer c.ex.mu.Unlock()
+
+	c.lastInteraction = time.Now()
+	c.lastCmd = name
+
+	cmd, errReply, ok := c.ex.lookupCommand(name, args)
+	if !ok {
+		if c.tx.active {
+			c.tx.aborted = true
+		}
+		c.out.WriteRaw(errReply)
+		return nil
+	}
+
+	if c.protocol == protocol.RESP2 && c.subscribed() && !slices.Contains(subscribedModeCommands, name) {
+		c.out.WriteRaw(errSubscribedMode(name))
+		return nil
+	}
+
+	if !c.ex.freeMemory() && c.deniedOOM(cmd) {
+		switch {
+		case name == "exec":
+			c.discard()
+		case c.tx.active:
+			c.tx.aborted = true
+		}
+		c.out.WriteRaw(errOOM)
+		return nil
+	}
+
+	switch name {
+	case "multi", "exec", "discard", "watch", "quit", "reset":
+	default:
+		if c.tx.active {
+			if cmd.flags&FlagNoMulti != 0 {
+				c.tx.aborted = true
+				c.out.WriteError("ERR Command not allowed inside a transaction")
+				return nil
+			}
+			c.out.WriteRaw(c.queue(name, args))
+			return nil
+		}
+	}
+
+	start := time.Now()
+	c.blockedFor = 0
+	err := cmd.call(c, args)
+	d := time.Since(start) - c.blockedFor
+	c.checkOutputLimit()
+	c.ex.evictClients()
+
+	c.logSlow(resp, d)
+	if cmd.flags&FlagFast != 0 {
+		c.ex.recordLatency(latencyFastCommand, d)
+	} else {
+		c.ex.recordLatency(latencyCommand, d)
+	}
+	return err
+}
+
+// ProtocolError replies to a request that could not be read. The connection
+// has to be closed once the reply is flushed, as the rest of the input cannot
+// be made sense of.
+func (c *Client) ProtocolError(err error) {
+	c.ex.mu.Lock()
+	defer c.ex.mu.Unlock()
+
+	c.out.WriteError("ERR " + err.Error())
+}
+
+// Flush writes the buffered replies to the connection. It waits for them to
+// be written with the executor lock released, so that a client which does not
+// read its replies holds up no one else.
+func (c *Client) Flush() error {
+	c.ex.mu.Lock()
+	err := c.out.Flush()
+	n := c.output.mark()
+	c.ex.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return c.output.wait(n)
+}
+
+// Close closes the connection and forgets the client. It is safe to call more
+// than once, which happens when a client is killed.
+func (c *Client) Close() {
+	c.ex.mu.Lock()
+	defer c.ex.mu.Unlock()
+
+	c.close()
+}
+
+func (c *Client) close() {
+	if c.closed {
+		return
+	}
+
+	// The writer closes the connection once it has written what is queued.
+	_ = c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+
+	c.closed = true
+	close(c.done)
+	c.unwatchAll()
+	c.unsubscribeAll()
+	delete(c.ex.clients, c.id)
+}
+
+// isWrite reports whether running name would write to the keyspace. For EXEC
+// that depends on what was queued.
+func (c *Client) isWrite(name string) bool {
+	if name == "exec" {
+		for _, cmd := range c.tx.queued {
+			if cmd, _ := c.ex.commands.get(cmd[0]); cmd.flags&FlagWrite != 0 {
+				return true
+			}
+		}
+		return false
+	}
+	cmd, _ := c.ex.commands.get(name)
+	return cmd.flags&FlagWrite != 0
+}
+
+// await waits for a blocking command's result with the executor lock
+// released, so the clients that will wake it up can run. The replies to the
+// commands before it in the pipeline are handed to the writer first. If the
+// client is closed meanwhile, await cancels the wait and returns the zero
+// value, which commands take as a timeout.
+func await[T any](c *Client, ch <-chan T, cancel func()) T {
+	if err := c.out.Flush(); err != nil {
+		log.Println(err)
+	}
+
+	c.blocked = true
+	start := time.Now()
+	c.ex.mu.Unlock()
+
+	defer func() {
+		c.ex.mu.Lock()
+		c.blocked = false
+		c.blockedFor += time.Since(start)
+	}()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-c.done:
+		cancel()
+		var zero T
+		return zero
+	}
+}
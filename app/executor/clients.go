@@ -0,0 +1,511 @@
+package executor
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// serverVersion is the Redis version the server reports being compatible with.
+const serverVersion = "7.4.0"
+
+var (
+	clientTypes = []string{"normal", "master", "replica", "slave", "pubsub"}
+
+	errNoSuchClient = errors.New("ERR No such client")
+
+	clientHelp = []string{
+		"GETNAME",
+		"    Return the name of the current connection.",
+		"ID",
+		"    Return the ID of the current connection.",
+		"INFO",
+		"    Return information about the current client connection.",
+		"KILL <ip:port>",
+		"    Kill connection made from <ip:port>.",
+		"KILL <option> <value> [<option> <value> [...]]",
+		"    Kill connections. Options are:",
+		"    * ADDR <ip:port>",
+		"      Kill connections made from the specified address",
+		"    * LADDR <ip:port>",
+		"      Kill connections made to specified local address",
+		"    * TYPE (NORMAL|MASTER|REPLICA|PUBSUB)",
+		"      Kill connections by type.",
+		"    * USER <username>",
+		"      Kill connections authenticated by <username>.",
+		"    * SKIPME (YES|NO)",
+		"      Skip killing current connection (default: yes).",
+		"    * ID <client-id>",
+		"      Kill connections by client id.",
+		"    * MAXAGE <maxage>",
+		"      Kill connections older than the specified age.",
+		"LIST [options ...]",
+		"    Return information about client connections. Options:",
+		"    * TYPE (NORMAL|MASTER|REPLICA|PUBSUB)",
+		"      Return clients of specified type.",
+		"    * ID <client-id> [<client-id> ...]",
+		"      Return clients of specified IDs only.",
+		"PAUSE <timeout> [WRITE|ALL]",
+		"    Suspend all, or just write, clients for <timeout> milliseconds.",
+		"UNPAUSE",
+		"    Stop the current client pause, resuming traffic.",
+		"SETNAME <name>",
+		"    Assign the name <name> to the current connection.",
+		"NO-EVICT (ON|OFF)",
+		"    Protect current client connection from eviction.",
+	}
+)
+
+// clientPause is the state of CLIENT PAUSE. While resume is open, clients
+// wait on it before running a command the pause applies to: every command
+// with all set, only writes otherwise.
+type clientPause struct {
+	mu     sync.Mutex
+	until  time.Time
+	all    bool
+	resume chan struct{}
+}
+
+// start pauses clients for d. Overlapping pauses keep the later deadline and
+// the stricter mode.
+func (p *clientPause) start(d time.Duration, all bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		p.until, p.all = until, all
+	} else {
+		p.all = p.all || all
+		if until.After(p.until) {
+			p.until = until
+		}
+	}
+
+	time.AfterFunc(d, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.resume != nil && !time.Now().Before(p.until) {
+			p.end()
+		}
+	})
+}
+
+func (p *clientPause) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resume != nil {
+		p.end()
+	}
+}
+
+// end resumes the waiting clients. p.mu must be held.
+func (p *clientPause) end() {
+	close(p.resume)
+	p.resume, p.all = nil, false
+}
+
+func (p *clientPause) wait(write bool) {
+	for {
+		p.mu.Lock()
+		resume := p.resume
+		paused := resume != nil && (p.all || write)
+		p.mu.Unlock()
+
+		if !paused {
+			return
+		}
+		<-resume
+	}
+}
+
+func (c *Client) handleClient(args []string) (string, error) {
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	wrongArgs := protocol.ErrorString("ERR wrong number of arguments for 'client|" + sub + "' command")
+
+	switch sub {
+	case "id":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return protocol.Integer(int(c.id)), nil
+	case "setname":
+		if len(args) != 1 {
+			return wrongArgs, nil
+		}
+		if !validClientName(args[0]) {
+			return protocol.ErrorString("ERR Client names cannot contain spaces, newlines or special characters."), nil
+		}
+
+		c.name = args[0]
+		return protocol.SimpleString("OK"), nil
+	case "getname":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		if c.name == "" {
+			return c.reply(nil), nil
+		}
+		return protocol.BulkString(c.name), nil
+	case "info":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return c.reply(protocol.VerbatimString{Format: "txt", Text: c.info() + "\n"}), nil
+	case "list":
+		return c.handleClientList(args)
+	case "kill":
+		if len(args) == 0 {
+			return wrongArgs, nil
+		}
+		return c.handleClientKill(args)
+	case "pause":
+		if len(args) != 1 && len(args) != 2 {
+			return wrongArgs, nil
+		}
+
+		ms, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return protocol.ErrorString("ERR timeout is not an integer or out of range"), nil
+		}
+		if ms < 0 {
+			return protocol.ErrorString("ERR timeout is negative"), nil
+		}
+
+		all := true
+		if len(args) == 2 {
+			switch strings.ToLower(args[1]) {
+			case "write":
+				all = false
+			case "all":
+			default:
+				return protocol.ErrorString(errSyntax.Error()), nil
+			}
+		}
+
+		c.ex.pause.start(time.Duration(ms)*time.Millisecond, all)
+		return protocol.SimpleString("OK"), nil
+	case "unpause":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+
+		c.ex.pause.stop()
+		return protocol.SimpleString("OK"), nil
+	case "no-evict":
+		if len(args) != 1 {
+			return wrongArgs, nil
+		}
+
+		switch strings.ToLower(args[0]) {
+		case "on":
+			c.noEvict = true
+		case "off":
+			c.noEvict = false
+		default:
+			return protocol.ErrorString(errSyntax.Error()), nil
+		}
+		return protocol.SimpleString("OK"), nil
+	case "help":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return helpReply("CLIENT", clientHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try CLIENT HELP."), nil
+	}
+}
+
+// handleHello implements HELLO [protover [AUTH username password] [SETNAME
+// clientname]], switching the connection's protocol and replying with the
+// server's details in the new one.
+func (c *Client) handleHello(args []string) (string, error) {
+	version := c.protocol
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return protocol.ErrorString("ERR Protocol version is not an integer or out of range"), nil
+		}
+		if v != protocol.RESP2 && v != protocol.RESP3 {
+			return protocol.ErrorString("NOPROTO unsupported protocol version"), nil
+		}
+		version = v
+		args = args[1:]
+	}
+
+	name, setName := "", false
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToLower(args[i]); {
+		case opt == "auth" && i+2 < len(args):
+			// Only the default user exists, and it has no password.
+			if args[i+1] != "default" {
+				return protocol.ErrorString("WRONGPASS invalid username-password pair or user is disabled."), nil
+			}
+			i += 2
+		case opt == "setname" && i+1 < len(args):
+			if !validClientName(args[i+1]) {
+				return protocol.ErrorString("ERR Client names cannot contain spaces, newlines or special characters."), nil
+			}
+			name, setName = args[i+1], true
+			i++
+		default:
+			return protocol.ErrorString("ERR Syntax error in HELLO option '" + args[i] + "'"), nil
+		}
+	}
+
+	c.protocol = version
+	c.out.SetVersion(version)
+	if setName {
+		c.name = name
+	}
+
+	return c.reply(protocol.Map{
+		"server", "redis",
+		"version", serverVersion,
+		"proto", c.protocol,
+		"id", int(c.id),
+		"mode", "standalone",
+		"role", "master",
+		"modules", []any{},
+	}), nil
+}
+
+// handleQuit implements QUIT: the connection is closed once the reply has
+// been written.
+func (c *Client) handleQuit(args []string) (string, error) {
+	c.closeAfterReply = true
+	return protocol.SimpleString("OK"), nil
+}
+
+// handleReset implements RESET, which puts the connection back in the state
+// of a new one: out of MULTI and every subscription, in database 0, speaking
+// RESP2 without a name, and subject to eviction.
+func (c *Client) handleReset(args []string) (string, error) {
+	c.discard()
+	c.unsubscribeAll()
+
+	c.db = 0
+	c.protocol = protocol.RESP2
+	c.out.SetVersion(protocol.RESP2)
+	c.name = ""
+	c.noEvict = false
+	return protocol.SimpleString("RESET"), nil
+}
+
+// handleClientList implements CLIENT LIST [TYPE type] [ID id [id ...]].
+func (c *Client) handleClientList(args []string) (string, error) {
+	var (
+		typ string
+		ids []int64
+	)
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToLower(args[i]); {
+		case opt == "type" && i+1 < len(args):
+			typ = strings.ToLower(args[i+1])
+			if !slices.Contains(clientTypes, typ) {
+				return protocol.ErrorString("ERR Unknown client type '" + args[i+1] + "'"), nil
+			}
+			i++
+		case opt == "id" && i+1 < len(args):
+			for i++; i < len(args); i++ {
+				id, err := strconv.ParseInt(args[i], 10, 64)
+				if err != nil || id <= 0 {
+					return protocol.ErrorString("ERR Invalid client ID"), nil
+				}
+				ids = append(ids, id)
+			}
+		default:
+			return protocol.ErrorString(errSyntax.Error()), nil
+		}
+	}
+
+	var list strings.Builder
+	for _, cl := range c.ex.sortedClients() {
+		if typ != "" && cl.clientType() != typ {
+			continue
+		}
+		if ids != nil && !slices.Contains(ids, cl.id) {
+			continue
+		}
+
+		list.WriteString(cl.info())
+		list.WriteByte('\n')
+	}
+
+	return c.reply(protocol.VerbatimString{Format: "txt", Text: list.String()}), nil
+}
+
+// handleClientKill implements both CLIENT KILL addr, which replies OK, and
+// the filter form, which replies with the number of clients killed.
+func (c *Client) handleClientKill(args []string) (string, error) {
+	if len(args) == 1 {
+		for _, cl := range c.ex.clients {
+			if cl.addr == args[0] {
+				c.kill(cl)
+				return protocol.SimpleString("OK"), nil
+			}
+		}
+		return protocol.ErrorString(errNoSuchClient.Error()), nil
+	}
+
+	if len(args)%2 != 0 {
+		return protocol.ErrorString(errSyntax.Error()), nil
+	}
+
+	var (
+		filters []func(cl *Client) bool
+		skipMe  = true
+	)
+	for i := 0; i < len(args); i += 2 {
+		opt, val := strings.ToLower(args[i]), args[i+1]
+
+		switch opt {
+		case "id":
+			id, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || id <= 0 {
+				return protocol.ErrorString("ERR client-id should be greater than 0"), nil
+			}
+			filters = append(filters, func(cl *Client) bool { return cl.id == id })
+		case "addr":
+			filters = append(filters, func(cl *Client) bool { return cl.addr == val })
+		case "laddr":
+			filters = append(filters, func(cl *Client) bool { return cl.laddr == val })
+		case "type":
+			typ := strings.ToLower(val)
+			if !slices.Contains(clientTypes, typ) {
+				return protocol.ErrorString("ERR Unknown client type '" + val + "'"), nil
+			}
+			filters = append(filters, func(cl *Client) bool { return cl.clientType() == typ })
+		case "user":
+			filters = append(filters, func(*Client) bool { return val == "default" })
+		case "skipme":
+			switch strings.ToLower(val) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return protocol.ErrorString(errSyntax.Error()), nil
+			}
+		case "maxage":
+			secs, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return protocol.ErrorString(errSyntax.Error()), nil
+			}
+			maxAge := time.Duration(secs) * time.Second
+			filters = append(filters, func(cl *Client) bool { return time.Since(cl.created) >= maxAge })
+		default:
+			return protocol.ErrorString(errSyntax.Error()), nil
+		}
+	}
+
+	var killed int
+	for _, cl := range c.ex.sortedClients() {
+		if skipMe && cl == c {
+			continue
+		}
+		if !slices.ContainsFunc(filters, func(match func(*Client) bool) bool { return !match(cl) }) {
+			c.kill(cl)
+			killed++
+		}
+	}
+	return protocol.Integer(killed), nil
+}
+
+// kill closes cl's connection. A client killing itself is closed once its
+// reply has been written.
+func (c *Client) kill(cl *Client) {
+	if cl == c {
+		c.closeAfterReply = true
+		return
+	}
+	cl.close()
+}
+
+// CloseAfterReply reports whether the connection must be closed once the last
+// reply has been written.
+func (c *Client) CloseAfterReply() bool {
+	c.ex.mu.Lock()
+	defer c.ex.mu.Unlock()
+
+	return c.closeAfterReply
+}
+
+func (c *Client) clientType() string {
+	if c.subscribed() {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+// info formats the client the way CLIENT LIST and CLIENT INFO show it.
+func (c *Client) info() string {
+	now := time.Now()
+
+	flags := ""
+	if c.tx.active {
+		flags += "x"
+	}
+	if c.blocked {
+		flags += "b"
+	}
+	if c.subscribed() {
+		flags += "P"
+	}
+	if c.noEvict {
+		flags += "e"
+	}
+	if c.closeAfterReply {
+		flags += "c"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+
+	multi := -1
+	if c.tx.active {
+		multi = len(c.tx.queued)
+	}
+
+	cmd := c.lastCmd
+	if cmd == "" {
+		cmd = "NULL"
+	}
+
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d sub=%d psub=%d ssub=%d multi=%d watch=%d omem=%d cmd=%s user=default resp=%d",
+		c.id, c.addr, c.laddr, c.name,
+		int(now.Sub(c.created).Seconds()), int(now.Sub(c.lastInteraction).Seconds()),
+		flags, c.db, len(c.channels), len(c.patterns), len(c.shardChannels), multi, len(c.tx.watched), c.outputMemory(), cmd, c.protocol)
+}
+
+func (e *Executor) sortedClients() []*Client {
+	res := make([]*Client, 0, len(e.clients))
+	for _, cl := range e.clients {
+		res = append(res, cl)
+	}
+
+	slices.SortFunc(res, func(a, b *Client) int { return cmp.Compare(a.id, b.id) })
+	return res
+}
+
+// validClientName accepts printable ASCII without spaces, as Redis does.
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -6,11 +6,7 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 )
 
-func handleEcho(args []string) (string, error) {
-	if len(args) == 0 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'echo' command"), nil
-	}
-
+func (c *Client) handleEcho(args []string) (string, error) {
 	if len(args) == 1 {
 		return protocol.BulkString(args[0]), nil
 	}
@@ -20,10 +16,19 @@ func handleEcho(args []string) (string, error) {
 		argsToUse[a] = args[a]
 	}
 
-	return protocol.Array(argsToUse), nil
+	return c.reply(argsToUse), nil
 }
 
-func handlePing(args []string) (string, error) {
+func (c *Client) handlePing(args []string) (string, error) {
+	if c.protocol == protocol.RESP2 && c.subscribed() {
+		// Subscribed clients get an array, so the reply reads like a message.
+		msg := ""
+		if len(args) > 0 {
+			msg = args[0]
+		}
+		return "*2\r\n" + protocol.BulkString("pong") + protocol.BulkString(msg), nil
+	}
+
 	if len(args) > 0 {
 		return protocol.ErrorString(strings.Join(args, " ")), nil
 	}
@@ -0,0 +1,238 @@
+package executor
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+var commandHelp = []string{
+	"(no subcommand)",
+	"    Return details about all Redis commands.",
+	"COUNT",
+	"    Return the total number of commands in this Redis server.",
+	"INFO [<command-name> ...]",
+	"    Return details about multiple Redis commands.",
+	"    If no command names are given, documentation details for all",
+	"    commands are returned.",
+	"DOCS [<command-name> ...]",
+	"    Return documentation details about multiple Redis commands.",
+	"    If no command names are given, documentation details for all",
+	"    commands are returned.",
+	"GETKEYS <full-command>",
+	"    Return the keys from a full Redis command.",
+}
+
+// handleCommand implements COMMAND, COMMAND COUNT, COMMAND INFO, COMMAND DOCS,
+// COMMAND GETKEYS and COMMAND HELP over the command table.
+func (c *Client) handleCommand(args []string) (string, error) {
+	if len(args) == 0 {
+		res := []any{}
+		for _, cmd := range c.ex.commands.sorted() {
+			res = append(res, cmd.info())
+		}
+		return c.reply(res), nil
+	}
+
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "count":
+		if len(args) != 0 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'command|count' command"), nil
+		}
+		return protocol.Integer(c.ex.commands.len()), nil
+	case "info":
+		if len(args) == 0 {
+			return c.handleCommand(nil)
+		}
+
+		res := make([]any, len(args))
+		for i, name := range args {
+			if cmd, ok := c.ex.commands.get(strings.ToLower(name)); ok {
+				res[i] = cmd.info()
+			}
+		}
+		return c.reply(res), nil
+	case "docs":
+		cmds := c.ex.commands.sorted()
+		if len(args) > 0 {
+			cmds = cmds[:0]
+			for _, name := range args {
+				if cmd, ok := c.ex.commands.get(strings.ToLower(name)); ok {
+					cmds = append(cmds, cmd)
+				}
+			}
+		}
+
+		res := make(protocol.Map, 0, 2*len(cmds))
+		for _, cmd := range cmds {
+			res = append(res, cmd.name, cmd.docs())
+		}
+		return c.reply(res), nil
+	case "getkeys":
+		if len(args) == 0 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'command|getkeys' command"), nil
+		}
+
+		cmd, ok := c.ex.commands.get(strings.ToLower(args[0]))
+		if !ok {
+			return protocol.ErrorString("ERR Invalid command specified"), nil
+		}
+		if n := len(args); (cmd.arity > 0 && n != cmd.arity) || n < -cmd.arity {
+			return protocol.ErrorString("ERR Invalid number of arguments specified for command"), nil
+		}
+
+		keys := cmd.keyArgs(args[1:])
+		if len(keys) == 0 {
+			return protocol.ErrorString("ERR The command has no key arguments"), nil
+		}
+
+		res := make([]any, len(keys))
+		for i, key := range keys {
+			res[i] = key
+		}
+		return c.reply(res), nil
+	case "help":
+		if len(args) != 0 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'command|help' command"), nil
+		}
+		return helpReply("COMMAND", commandHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try COMMAND HELP."), nil
+	}
+}
+
+// info is the command's entry in COMMAND INFO: name, arity, flags, key
+// positions, ACL categories, then tips, key specifications and subcommands,
+// which are left empty.
+func (cmd command) info() []any {
+	var flags []any
+	for _, f := range commandFlagNames {
+		if cmd.flags&f.flag != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	if cmd.getKeys != nil {
+		flags = append(flags, "movablekeys")
+	}
+
+	var categories []any
+	for _, c := range cmd.aclCategories() {
+		categories = append(categories, c)
+	}
+
+	return []any{
+		cmd.name, cmd.arity, protocol.Set(flags),
+		cmd.keys.first, cmd.keys.last, cmd.keys.step,
+		protocol.Set(categories), []any{}, []any{}, []any{},
+	}
+}
+
+// groupCategories maps command groups to their ACL category where the names
+// differ. Server and registered commands only get the categories of their
+// flags.
+var groupCategories = map[string]string{
+	"transactions": "transaction",
+	"generic":      "keyspace",
+	"server":       "",
+	"module":       "",
+}
+
+// aclCategories derives the command's ACL categories from its group and
+// flags, the way Redis assigns most of them.
+func (cmd command) aclCategories() []string {
+	group := cmd.group
+	if c, ok := groupCategories[group]; ok {
+		group = c
+	}
+
+	var res []string
+	add := func(c string) {
+		if !slices.Contains(res, c) {
+			res = append(res, c)
+		}
+	}
+
+	if group != "" {
+		add("@" + group)
+	}
+	switch {
+	case cmd.flags&FlagWrite != 0:
+		add("@write")
+	case cmd.flags&FlagReadonly != 0:
+		add("@read")
+	}
+	if cmd.flags&FlagAdmin != 0 {
+		add("@admin")
+		add("@dangerous")
+	}
+	if cmd.flags&FlagPubSub != 0 {
+		add("@pubsub")
+	}
+	if cmd.flags&FlagBlocking != 0 {
+		add("@blocking")
+	}
+	if cmd.flags&FlagFast != 0 {
+		add("@fast")
+	} else {
+		add("@slow")
+	}
+	return res
+}
+
+// docs is the command's entry in COMMAND DOCS.
+func (cmd command) docs() protocol.Map {
+	doc := commandDocs[cmd.name]
+	return protocol.Map{
+		"summary", doc.summary,
+		"since", doc.since,
+		"group", cmd.group,
+	}
+}
+
+// keyArgs returns the keys among args, which exclude the command name.
+func (cmd command) keyArgs(args []string) []string {
+	if cmd.getKeys != nil {
+		var keys []string
+		for _, i := range cmd.getKeys(args) {
+			keys = append(keys, args[i])
+		}
+		return keys
+	}
+
+	if cmd.keys.first == 0 {
+		return nil
+	}
+
+	last := cmd.keys.last
+	if last < 0 {
+		last += len(args) + 1
+	}
+
+	var keys []string
+	for i := cmd.keys.first; i <= last && i <= len(args); i += cmd.keys.step {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+// streamsKeys finds the keys of XREAD and XREADGROUP: the first half of the
+// arguments after STREAMS.
+func streamsKeys(args []string) []int {
+	for i, arg := range args {
+		if strings.ToLower(arg) != "streams" {
+			continue
+		}
+
+		n := (len(args) - i - 1) / 2
+		keys := make([]int, n)
+		for j := range keys {
+			keys[j] = i + 1 + j
+		}
+		return keys
+	}
+	return nil
+}
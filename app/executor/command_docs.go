@@ -0,0 +1,79 @@
+package executor
+
+// commandDocs holds the COMMAND DOCS summaries, taken from the Redis
+// documentation.
+var commandDocs = map[string]struct{ summary, since string }{
+	"multi":          {"Starts a transaction.", "1.2.0"},
+	"exec":           {"Executes all commands in a transaction.", "1.2.0"},
+	"discard":        {"Discards a transaction.", "2.0.0"},
+	"watch":          {"Monitors changes to keys to determine the execution of a transaction.", "2.2.0"},
+	"unwatch":        {"Forgets about watched keys of a transaction.", "2.2.0"},
+	"client":         {"A container for client connection commands.", "2.4.0"},
+	"hello":          {"Handshakes with the Redis server.", "6.0.0"},
+	"quit":           {"Closes the connection.", "1.0.0"},
+	"reset":          {"Resets the connection.", "6.2.0"},
+	"command":        {"Returns detailed information about all commands.", "2.8.13"},
+	"select":         {"Changes the selected database.", "1.0.0"},
+	"swapdb":         {"Swaps two Redis databases.", "4.0.0"},
+	"move":           {"Moves a key to another database.", "1.0.0"},
+	"flushdb":        {"Remove all keys from the current database.", "1.0.0"},
+	"flushall":       {"Removes all keys from all databases.", "1.0.0"},
+	"dbsize":         {"Returns the number of keys in the database.", "1.0.0"},
+	"object":         {"A container for object introspection commands.", "2.2.3"},
+	"memory":         {"A container for memory diagnostics commands.", "4.0.0"},
+	"info":           {"Returns information and statistics about the server.", "1.0.0"},
+	"slowlog":        {"A container for slow log commands.", "2.2.12"},
+	"latency":        {"A container for latency diagnostics commands.", "2.8.13"},
+	"shutdown":       {"Synchronously saves the database(s) to disk and shuts down the Redis server.", "1.0.0"},
+	"echo":           {"Returns the given string.", "1.0.0"},
+	"ping":           {"Returns the server's liveliness response.", "1.0.0"},
+	"set":            {"Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", "1.0.0"},
+	"get":            {"Returns the string value of a key.", "1.0.0"},
+	"rpush":          {"Appends one or more elements to a list. Creates the key if it doesn't exist.", "1.0.0"},
+	"lpush":          {"Prepends one or more elements to a list. Creates the key if it doesn't exist.", "1.0.0"},
+	"lrange":         {"Returns a range of elements from a list.", "1.0.0"},
+	"llen":           {"Returns the length of a list.", "1.0.0"},
+	"rpop":           {"Returns and removes the last elements of a list. Deletes the list if the last element was popped.", "1.0.0"},
+	"lpop":           {"Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", "1.0.0"},
+	"blpop":          {"Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", "2.0.0"},
+	"type":           {"Determines the type of value stored at a key.", "1.0.0"},
+	"xadd":           {"Appends a new message to a stream. Creates the key if it doesn't exist.", "5.0.0"},
+	"xrange":         {"Returns the messages from a stream within a range of IDs.", "5.0.0"},
+	"xrevrange":      {"Returns the messages from a stream within a range of IDs in reverse order.", "5.0.0"},
+	"xread":          {"Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", "5.0.0"},
+	"xlen":           {"Return the number of messages in a stream.", "5.0.0"},
+	"xdel":           {"Returns the number of messages after removing them from a stream.", "5.0.0"},
+	"xtrim":          {"Deletes messages from the beginning of a stream.", "5.0.0"},
+	"xinfo":          {"A container for stream introspection commands.", "5.0.0"},
+	"xgroup":         {"A container for consumer groups commands.", "5.0.0"},
+	"xreadgroup":     {"Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.", "5.0.0"},
+	"xack":           {"Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.", "5.0.0"},
+	"xpending":       {"Returns the information and entries from a stream consumer group's pending entries list.", "5.0.0"},
+	"xclaim":         {"Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.", "5.0.0"},
+	"xautoclaim":     {"Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.", "6.2.0"},
+	"setbit":         {"Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.", "2.2.0"},
+	"getbit":         {"Returns a bit value by offset.", "2.2.0"},
+	"bitcount":       {"Counts the number of set bits (population counting) in a string.", "2.6.0"},
+	"bitpos":         {"Finds the first set (1) or clear (0) bit in a string.", "2.8.7"},
+	"bitop":          {"Performs bitwise operations on multiple strings, and stores the result.", "2.6.0"},
+	"bitfield":       {"Performs arbitrary bitfield integer operations on strings.", "3.2.0"},
+	"bitfield_ro":    {"Performs arbitrary read-only bitfield integer operations on strings.", "6.0.0"},
+	"pfadd":          {"Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.", "2.8.9"},
+	"pfcount":        {"Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).", "2.8.9"},
+	"pfmerge":        {"Merges one or more HyperLogLog values into a single key.", "2.8.9"},
+	"geoadd":         {"Adds one or more members to a geospatial index. The key is created if it doesn't exist.", "3.2.0"},
+	"geopos":         {"Returns the longitude and latitude of members from a geospatial index.", "3.2.0"},
+	"geodist":        {"Returns the distance between two members of a geospatial index.", "3.2.0"},
+	"geohash":        {"Returns members from a geospatial index as geohash strings.", "3.2.0"},
+	"geosearch":      {"Queries a geospatial index for members inside an area of a box or a circle.", "6.2.0"},
+	"geosearchstore": {"Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result.", "6.2.0"},
+	"subscribe":      {"Listens for messages published to channels.", "2.0.0"},
+	"unsubscribe":    {"Stops listening to messages posted to channels.", "2.0.0"},
+	"psubscribe":     {"Listens for messages published to channels that match one or more patterns.", "2.0.0"},
+	"punsubscribe":   {"Stops listening to messages published to channels that match one or more patterns.", "2.0.0"},
+	"ssubscribe":     {"Listens for messages published to shard channels.", "7.0.0"},
+	"sunsubscribe":   {"Stops listening to messages posted to shard channels.", "7.0.0"},
+	"publish":        {"Posts a message to a channel.", "2.0.0"},
+	"spublish":       {"Post a message to a shard channel", "7.0.0"},
+	"pubsub":         {"A container for Pub/Sub commands.", "2.8.0"},
+}
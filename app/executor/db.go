@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+var errDBIndex = protocol.ErrorString("ERR DB index is out of range")
+
+// dbIndex parses a database index, reporting whether it is in range.
+func (e *Executor) dbIndex(arg string) (int, bool) {
+	i, err := strconv.Atoi(arg)
+	return i, err == nil && i >= 0 && i < len(e.dbs)
+}
+
+func (c *Client) handleSelect(args []string) (string, error) {
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+	}
+
+	i, ok := c.ex.dbIndex(args[0])
+	if !ok {
+		return errDBIndex, nil
+	}
+
+	c.db = i
+	return protocol.SimpleString("OK"), nil
+}
+
+// handleSwapDB swaps the contents of two databases for every client at once.
+// Transactions watching keys that exist in either fail, as their values may
+// have changed, while blocked clients keep waiting on the same database
+// number.
+func (c *Client) handleSwapDB(args []string) (string, error) {
+	ex := c.ex
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		return protocol.ErrorString("ERR invalid first DB index"), nil
+	}
+	if _, err := strconv.Atoi(args[1]); err != nil {
+		return protocol.ErrorString("ERR invalid second DB index"), nil
+	}
+
+	i, ok := ex.dbIndex(args[0])
+	j, ok2 := ex.dbIndex(args[1])
+	if !ok || !ok2 {
+		return errDBIndex, nil
+	}
+
+	a, b := ex.dbs[i], ex.dbs[j]
+	a.Swap(b)
+
+	for _, cl := range ex.clients {
+		for w := range cl.tx.watched {
+			if (w.db == a || w.db == b) && (a.Exists(w.key) || b.Exists(w.key)) {
+				cl.tx.dirty = true
+			}
+		}
+	}
+	return protocol.SimpleString("OK"), nil
+}
+
+func (c *Client) handleMove(args []string) (string, error) {
+	if _, err := strconv.Atoi(args[1]); err != nil {
+		return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+	}
+
+	i, ok := c.ex.dbIndex(args[1])
+	if !ok {
+		return errDBIndex, nil
+	}
+	if i == c.db {
+		return protocol.ErrorString("ERR source and destination objects are the same"), nil
+	}
+
+	if c.keyspace().Move(args[0], c.ex.dbs[i]) {
+		return protocol.Integer(1), nil
+	}
+	return protocol.Integer(0), nil
+}
+
+// parseFlushMode checks the optional ASYNC or SYNC argument of FLUSHDB and
+// FLUSHALL. Either way the old data is released in the background by the
+// garbage collector, so they behave the same.
+func parseFlushMode(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+
+	mode := strings.ToLower(args[0])
+	return len(args) == 1 && (mode == "async" || mode == "sync")
+}
+
+func (c *Client) handleFlushDB(args []string) (string, error) {
+	if !parseFlushMode(args) {
+		return protocol.ErrorString(errSyntax.Error()), nil
+	}
+
+	c.keyspace().Flush()
+	return protocol.SimpleString("OK"), nil
+}
+
+func (c *Client) handleFlushAll(args []string) (string, error) {
+	if !parseFlushMode(args) {
+		return protocol.ErrorString(errSyntax.Error()), nil
+	}
+
+	for _, db := range c.ex.dbs {
+		db.Flush()
+	}
+	return protocol.SimpleString("OK"), nil
+}
+
+func (c *Client) handleDBSize(args []string) (string, error) {
+	return protocol.Integer(c.keyspace().Len()), nil
+}
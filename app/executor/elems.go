@@ -3,26 +3,20 @@ package executor
 import (
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/cache"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 )
 
-func handleGet(args []string) (string, error) {
-	if len(args) < 1 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'get' command"), nil
-	}
-	val, ok := cache.Get(args[0])
+func (c *Client) handleGet(args []string) (string, error) {
+	val, ok := c.keyspace().Get(args[0])
 	if !ok {
-		return protocol.BulkString(""), nil
+		return c.reply(nil), nil
 	}
 	return protocol.BulkString(val.(string)), nil
 }
 
-func handleSet(args []string) (string, error) {
-	if len(args) < 2 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'set' command"), nil
-	}
+func (c *Client) handleSet(args []string) (string, error) {
 	exArgKey := "px"
 	var expArg string
 	for i := 2; i < len(args); i++ {
@@ -46,6 +40,32 @@ func handleSet(args []string) (string, error) {
 		}
 	}
 
-	cache.Set(args[0], args[1], ex)
+	ks := c.keyspace()
+	ks.Set(args[0], args[1])
+	if ex > 0 {
+		e, key := c.ex, args[0]
+		at := time.Now().Add(time.Duration(ex) * time.Millisecond)
+		ks.Expire(key, at)
+		time.AfterFunc(time.Until(at), func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+
+			e.expire(key, at)
+		})
+	}
 	return protocol.SimpleString("OK"), nil
 }
+
+// expire removes key once it is due. The key is looked up in every database,
+// as MOVE takes its expiry along, and is left alone if it has been given
+// another value or expiry since.
+func (e *Executor) expire(key string, at time.Time) {
+	for _, db := range e.dbs {
+		if t, ok := db.ExpiresAt(key); ok && t.Equal(at) {
+			start := time.Now()
+			db.Remove(key)
+			e.stats.expiredKeys++
+			e.recordLatency(latencyExpireDel, time.Since(start))
+		}
+	}
+}
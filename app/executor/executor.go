@@ -1,70 +1,335 @@
 package executor
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 )
 
 var (
-	errorString = protocol.ErrorString("ERR unknown command")
+	errSyntax       = errors.New("ERR syntax error")
+	errBitFieldType = errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	errGeoUnit      = errors.New("ERR unsupported unit provided. please use M, KM, FT, MI")
 )
 
-func Execute(resp protocol.RESP) (string, error) {
+// command is an entry of the command table. arity follows Redis: it counts
+// the command name, and a negative value means at least -arity arguments.
+// Commands with large replies set writeHandler instead of handler to encode
+// them straight into the client's reply buffer.
+//
+// keys locates the key arguments for COMMAND and cluster-aware clients;
+// commands whose keys depend on the other arguments set getKeys instead.
+// group is the command's group in COMMAND DOCS, which also gives its main
+// ACL category.
+type command struct {
+	name         string
+	handler      func(c *Client, args []string) (string, error)
+	writeHandler func(c *Client, w *protocol.Writer, args []string) error
+	arity        int
+	flags        CommandFlags
+	keys         keySpec
+	getKeys      func(args []string) []int
+	group        string
+	// noTouch commands look at their keys without counting as an access.
+	noTouch bool
+}
+
+// keySpec gives the positions of the keys of a command the way COMMAND
+// reports them: first and last count the command name as 0, a negative last
+// counts from the end, and step is the distance between two keys.
+type keySpec struct {
+	first, last, step int
+}
+
+// CommandFlags describe how a command behaves, as reported by COMMAND INFO.
+type CommandFlags uint
+
+const (
+	// FlagWrite marks commands that may modify the keyspace.
+	FlagWrite CommandFlags = 1 << iota
+	// FlagReadonly marks commands that only read keys.
+	FlagReadonly
+	// FlagDenyOOM marks commands that may grow memory usage.
+	FlagDenyOOM
+	FlagAdmin
+	FlagPubSub
+	FlagNoScript
+	// FlagBlocking marks commands that may block the client.
+	FlagBlocking
+	// FlagFast marks commands that run in constant or logarithmic time.
+	FlagFast
+	// FlagNoMulti rejects the command inside MULTI.
+	FlagNoMulti
+)
+
+// commandFlagNames are the names COMMAND INFO reports for CommandFlags.
+var commandFlagNames = []struct {
+	flag CommandFlags
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadonly, "readonly"},
+	{FlagDenyOOM, "denyoom"},
+	{FlagAdmin, "admin"},
+	{FlagPubSub, "pubsub"},
+	{FlagNoScript, "noscript"},
+	{FlagBlocking, "blocking"},
+	{FlagFast, "fast"},
+	{FlagNoMulti, "no_multi"},
+}
+
+// builtinCommands is the command table every executor starts from, before
+// commands of its own are registered.
+var builtinCommands map[string]command
+
+func init() {
 	var (
-		cmd  string
-		args []string
+		oneKey  = keySpec{1, 1, 1}
+		allKeys = keySpec{1, -1, 1}
 	)
 
-	if resp == nil {
-		return errorString, nil
+	builtinCommands = map[string]command{
+		"multi":    {handler: (*Client).handleMulti, arity: 1, flags: FlagNoScript | FlagFast, group: "transactions"},
+		"exec":     {handler: (*Client).handleExec, arity: 1, flags: FlagNoScript, group: "transactions"},
+		"discard":  {handler: (*Client).handleDiscard, arity: 1, flags: FlagNoScript | FlagFast, group: "transactions"},
+		"watch":    {handler: (*Client).handleWatch, arity: -2, flags: FlagNoScript | FlagFast, keys: allKeys, group: "transactions"},
+		"unwatch":  {handler: (*Client).handleUnwatch, arity: 1, flags: FlagNoScript | FlagFast, group: "transactions"},
+		"client":   {handler: (*Client).handleClient, arity: -2, flags: FlagNoScript, group: "connection"},
+		"hello":    {handler: (*Client).handleHello, arity: -1, flags: FlagNoScript | FlagFast, group: "connection"},
+		"quit":     {handler: (*Client).handleQuit, arity: -1, flags: FlagNoScript | FlagFast, group: "connection"},
+		"reset":    {handler: (*Client).handleReset, arity: 1, flags: FlagNoScript | FlagFast, group: "connection"},
+		"command":  {handler: (*Client).handleCommand, arity: -1, group: "server"},
+		"select":   {handler: (*Client).handleSelect, arity: 2, flags: FlagFast, group: "connection"},
+		"swapdb":   {handler: (*Client).handleSwapDB, arity: 3, flags: FlagWrite | FlagFast, group: "server"},
+		"move":     {handler: (*Client).handleMove, arity: 3, flags: FlagWrite | FlagFast, keys: oneKey, group: "generic"},
+		"flushdb":  {handler: (*Client).handleFlushDB, arity: -1, flags: FlagWrite, group: "server"},
+		"flushall": {handler: (*Client).handleFlushAll, arity: -1, flags: FlagWrite, group: "server"},
+		"dbsize":   {handler: (*Client).handleDBSize, arity: 1, flags: FlagReadonly | FlagFast, group: "server"},
+		"object":   {handler: (*Client).handleObject, arity: -2, flags: FlagReadonly, keys: keySpec{2, 2, 1}, group: "generic", noTouch: true},
+		"memory":   {handler: (*Client).handleMemory, arity: -2, flags: FlagReadonly, keys: keySpec{2, 2, 1}, group: "server", noTouch: true},
+		"info":     {handler: (*Client).handleInfo, arity: -1, group: "server"},
+		"slowlog":  {handler: (*Client).handleSlowLog, arity: -2, flags: FlagAdmin, group: "server"},
+		"latency":  {handler: (*Client).handleLatency, arity: -2, flags: FlagAdmin | FlagNoScript, group: "server"},
+		"shutdown": {handler: (*Client).handleShutdown, arity: -1, flags: FlagAdmin | FlagNoScript | FlagNoMulti, group: "server"},
+
+		"echo":           {handler: (*Client).handleEcho, arity: 2, flags: FlagFast, group: "connection"},
+		"ping":           {handler: (*Client).handlePing, arity: -1, flags: FlagFast, group: "connection"},
+		"set":            {handler: (*Client).handleSet, arity: -3, flags: FlagWrite | FlagDenyOOM, keys: oneKey, group: "string"},
+		"get":            {handler: (*Client).handleGet, arity: 2, flags: FlagReadonly | FlagFast, keys: oneKey, group: "string"},
+		"rpush":          {handler: (*Client).handleRPush, arity: -3, flags: FlagWrite | FlagDenyOOM | FlagFast, keys: oneKey, group: "list"},
+		"lpush":          {handler: (*Client).handleLPush, arity: -3, flags: FlagWrite | FlagDenyOOM | FlagFast, keys: oneKey, group: "list"},
+		"lrange":         {writeHandler: (*Client).handleLRange, arity: 4, flags: FlagReadonly, keys: oneKey, group: "list"},
+		"llen":           {handler: (*Client).handleLLen, arity: 2, flags: FlagReadonly | FlagFast, keys: oneKey, group: "list"},
+		"rpop":           {writeHandler: (*Client).handleRPop, arity: -2, flags: FlagWrite | FlagFast, keys: oneKey, group: "list"},
+		"lpop":           {writeHandler: (*Client).handleLPop, arity: -2, flags: FlagWrite | FlagFast, keys: oneKey, group: "list"},
+		"blpop":          {handler: (*Client).handleBLPop, arity: 3, flags: FlagWrite | FlagBlocking, keys: oneKey, group: "list"},
+		"type":           {handler: (*Client).handleType, arity: 2, flags: FlagReadonly | FlagFast, keys: oneKey, group: "generic"},
+		"xadd":           {handler: (*Client).handleXAdd, arity: -5, flags: FlagWrite | FlagDenyOOM | FlagFast, keys: oneKey, group: "stream"},
+		"xrange":         {writeHandler: (*Client).handleXRange, arity: -4, flags: FlagReadonly, keys: oneKey, group: "stream"},
+		"xrevrange":      {writeHandler: (*Client).handleXRevRange, arity: -4, flags: FlagReadonly, keys: oneKey, group: "stream"},
+		"xread":          {writeHandler: (*Client).handleXRead, arity: -4, flags: FlagReadonly | FlagBlocking, getKeys: streamsKeys, group: "stream"},
+		"xlen":           {handler: (*Client).handleXLen, arity: 2, flags: FlagReadonly | FlagFast, keys: oneKey, group: "stream"},
+		"xdel":           {handler: (*Client).handleXDel, arity: -3, flags: FlagWrite | FlagFast, keys: oneKey, group: "stream"},
+		"xtrim":          {handler: (*Client).handleXTrim, arity: -4, flags: FlagWrite, keys: oneKey, group: "stream"},
+		"xinfo":          {handler: (*Client).handleXInfo, arity: -2, flags: FlagReadonly, keys: keySpec{2, 2, 1}, group: "stream"},
+		"xgroup":         {handler: (*Client).handleXGroup, arity: -2, flags: FlagWrite | FlagDenyOOM, keys: keySpec{2, 2, 1}, group: "stream"},
+		"xreadgroup":     {writeHandler: (*Client).handleXReadGroup, arity: -7, flags: FlagWrite | FlagBlocking, getKeys: streamsKeys, group: "stream"},
+		"xack":           {handler: (*Client).handleXAck, arity: -4, flags: FlagWrite | FlagFast, keys: oneKey, group: "stream"},
+		"xpending":       {writeHandler: (*Client).handleXPending, arity: -3, flags: FlagReadonly, keys: oneKey, group: "stream"},
+		"xclaim":         {writeHandler: (*Client).handleXClaim, arity: -6, flags: FlagWrite | FlagFast, keys: oneKey, group: "stream"},
+		"xautoclaim":     {writeHandler: (*Client).handleXAutoClaim, arity: -6, flags: FlagWrite | FlagFast, keys: oneKey, group: "stream"},
+		"setbit":         {handler: (*Client).handleSetBit, arity: 4, flags: FlagWrite | FlagDenyOOM, keys: oneKey, group: "bitmap"},
+		"getbit":         {handler: (*Client).handleGetBit, arity: 3, flags: FlagReadonly | FlagFast, keys: oneKey, group: "bitmap"},
+		"bitcount":       {handler: (*Client).handleBitCount, arity: -2, flags: FlagReadonly, keys: oneKey, group: "bitmap"},
+		"bitpos":         {handler: (*Client).handleBitPos, arity: -3, flags: FlagReadonly, keys: oneKey, group: "bitmap"},
+		"bitop":          {handler: (*Client).handleBitOp, arity: -4, flags: FlagWrite | FlagDenyOOM, keys: keySpec{2, -1, 1}, group: "bitmap"},
+		"bitfield":       {handler: (*Client).handleBitField, arity: -2, flags: FlagWrite | FlagDenyOOM, keys: oneKey, group: "bitmap"},
+		"bitfield_ro":    {handler: (*Client).handleBitFieldRO, arity: -2, flags: FlagReadonly | FlagFast, keys: oneKey, group: "bitmap"},
+		"pfadd":          {handler: (*Client).handlePFAdd, arity: -2, flags: FlagWrite | FlagDenyOOM | FlagFast, keys: oneKey, group: "hyperloglog"},
+		"pfcount":        {handler: (*Client).handlePFCount, arity: -2, flags: FlagWrite, keys: allKeys, group: "hyperloglog"},
+		"pfmerge":        {handler: (*Client).handlePFMerge, arity: -2, flags: FlagWrite | FlagDenyOOM, keys: allKeys, group: "hyperloglog"},
+		"geoadd":         {handler: (*Client).handleGeoAdd, arity: -5, flags: FlagWrite | FlagDenyOOM, keys: oneKey, group: "geo"},
+		"geopos":         {writeHandler: (*Client).handleGeoPos, arity: -2, flags: FlagReadonly, keys: oneKey, group: "geo"},
+		"geodist":        {handler: (*Client).handleGeoDist, arity: -4, flags: FlagReadonly, keys: oneKey, group: "geo"},
+		"geohash":        {writeHandler: (*Client).handleGeoHash, arity: -2, flags: FlagReadonly, keys: oneKey, group: "geo"},
+		"geosearch":      {writeHandler: (*Client).handleGeoSearch, arity: -7, flags: FlagReadonly, keys: oneKey, group: "geo"},
+		"geosearchstore": {handler: (*Client).handleGeoSearchStore, arity: -8, flags: FlagWrite | FlagDenyOOM, keys: keySpec{1, 2, 1}, group: "geo"},
+		"subscribe":      {handler: (*Client).handleSubscribe, arity: -2, flags: FlagPubSub | FlagNoScript | FlagNoMulti, group: "pubsub"},
+		"unsubscribe":    {handler: (*Client).handleUnsubscribe, arity: -1, flags: FlagPubSub | FlagNoScript | FlagNoMulti, group: "pubsub"},
+		"psubscribe":     {handler: (*Client).handlePSubscribe, arity: -2, flags: FlagPubSub | FlagNoScript | FlagNoMulti, group: "pubsub"},
+		"punsubscribe":   {handler: (*Client).handlePUnsubscribe, arity: -1, flags: FlagPubSub | FlagNoScript | FlagNoMulti, group: "pubsub"},
+		"ssubscribe":     {handler: (*Client).handleSSubscribe, arity: -2, flags: FlagPubSub | FlagNoScript | FlagNoMulti, keys: allKeys, group: "pubsub"},
+		"sunsubscribe":   {handler: (*Client).handleSUnsubscribe, arity: -1, flags: FlagPubSub | FlagNoScript | FlagNoMulti, keys: allKeys, group: "pubsub"},
+		"publish":        {handler: (*Client).handlePublish, arity: 3, flags: FlagPubSub | FlagFast, group: "pubsub"},
+		"spublish":       {handler: (*Client).handleSPublish, arity: 3, flags: FlagPubSub | FlagFast, keys: oneKey, group: "pubsub"},
+		"pubsub":         {handler: (*Client).handlePubSub, arity: -2, flags: FlagPubSub, group: "pubsub"},
 	}
 
-	if delim, ok := resp.IsArray(); ok {
-		raw := strings.Split(resp.String(), delim)
-		if len(raw) > 0 {
-			cmd = raw[0]
-		}
-		if len(raw) > 1 {
-			args = raw[1:]
+	for name, cmd := range builtinCommands {
+		cmd.name = name
+		builtinCommands[name] = cmd
+	}
+}
+
+// commandTable is an executor's commands by name. It has a lock of its own,
+// as clients check whether a command is a write before taking the executor
+// lock, to honour CLIENT PAUSE.
+type commandTable struct {
+	mu     sync.RWMutex
+	byName map[string]command
+}
+
+func newCommandTable() *commandTable {
+	return &commandTable{byName: maps.Clone(builtinCommands)}
+}
+
+func (t *commandTable) get(name string) (command, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	cmd, ok := t.byName[name]
+	return cmd, ok
+}
+
+func (t *commandTable) len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.byName)
+}
+
+// sorted returns the commands in alphabetical order.
+func (t *commandTable) sorted() []command {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return slices.SortedFunc(maps.Values(t.byName), func(a, b command) int {
+		return strings.Compare(a.name, b.name)
+	})
+}
+
+// errUnknownCommand quotes the start of each argument like Redis does, with
+// newlines blanked out to keep the error on one line.
+func errUnknownCommand(name string, args []string) string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "ERR unknown command '%s', with args beginning with: ", name)
+	for _, arg := range args {
+		if len(arg) > 128 {
+			arg = arg[:128]
 		}
-	} else {
-		cmd = resp.String()
+		fmt.Fprintf(&msg, "'%s' ", strings.NewReplacer("\r", " ", "\n", " ").Replace(arg))
+	}
+	return protocol.ErrorString(msg.String())
+}
+
+// lookupCommand finds name in the command table and checks the number of
+// arguments against its arity, returning the error reply when either fails.
+func (e *Executor) lookupCommand(name string, args []string) (command, string, bool) {
+	cmd, ok := e.commands.get(name)
+	if !ok {
+		return command{}, errUnknownCommand(name, args), false
+	}
+
+	if n := len(args) + 1; (cmd.arity > 0 && n != cmd.arity) || n < -cmd.arity {
+		return command{}, protocol.ErrorString("ERR wrong number of arguments for '" + name + "' command"), false
+	}
+	return cmd, "", true
+}
+
+// call runs the command and writes its reply to the client's buffer. Its
+// keys then count as accessed for eviction, unless it is a noTouch command.
+func (cmd command) call(c *Client, args []string) error {
+	e := c.ex
+	e.countKeyLookups(cmd, c, args)
+	e.stats.commands++
+	e.stats.ops.record(time.Now(), e.stats.commands)
+
+	err := cmd.run(c, args)
+	if cmd.noTouch {
+		return err
+	}
+
+	db := c.keyspace()
+	for _, key := range cmd.keyArgs(args) {
+		db.Access(key)
+	}
+	return err
+}
+
+func (cmd command) run(c *Client, args []string) error {
+	if cmd.writeHandler != nil {
+		return cmd.writeHandler(c, c.out, args)
+	}
+
+	r, err := cmd.handler(c, args)
+	if err != nil {
+		return err
+	}
+
+	c.out.WriteRaw(r)
+	return nil
+}
+
+// reply encodes v in the client's protocol version.
+func (c *Client) reply(v any) string {
+	return protocol.Encode(v, c.protocol)
+}
+
+// nullArrayReply is the null reply of commands that otherwise reply with an
+// array, which RESP2 tells apart from a null bulk string.
+func (c *Client) nullArrayReply() string {
+	if c.protocol == protocol.RESP3 {
+		return protocol.Nulls()
+	}
+	return protocol.NullArray()
+}
+
+// writeKeyed writes the [key, value] pairs of XREAD and XREADGROUP, which
+// RESP3 sends as a map.
+func writeKeyed(w *protocol.Writer, pairs []any) {
+	if w.Version() < protocol.RESP3 {
+		w.WriteValue(pairs)
+		return
 	}
 
-	switch strings.ToLower(cmd) {
-	case "echo":
-		return handleEcho(args)
-	case "ping":
-		return handlePing(args)
-	case "set":
-		return handleSet(args)
-	case "get":
-		return handleGet(args)
-	case "rpush":
-		return handleRPush(args)
-	case "lpush":
-		return handleLPush(args)
-	case "lrange":
-		return handleLRange(args)
-	case "llen":
-		return handleLLen(args)
-	case "rpop":
-		return handleRPop(args)
-	case "lpop":
-		return handleLPop(args)
-	case "blpop":
-		return handleBLPop(args)
-	case "type":
-		return handleType(args)
-	case "xadd":
-		return handleXAdd(args)
-	case "xrange":
-		return handleXRange(args)
-	case "xread":
-		return handleXRead(args)
-	default:
-		return errorString, nil
+	w.WriteMapHeader(len(pairs))
+	for _, p := range pairs {
+		kv := p.([]any)
+		w.WriteValue(kv[0])
+		w.WriteValue(kv[1])
+	}
+}
+
+// helpReply is the reply of a HELP subcommand, as Redis formats it: a line
+// introducing the subcommands of name, the lines describing them, then HELP
+// itself, all as simple strings.
+func helpReply(name string, lines ...string) string {
+	lines = slices.Concat(
+		[]string{name + " <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"},
+		lines,
+		[]string{"HELP", "    Print this help."},
+	)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(lines))
+	for _, line := range lines {
+		b.WriteString(protocol.SimpleString(line))
+	}
+	return b.String()
+}
 
+// parseCommand splits a request into the lower cased command name and its
+// arguments.
+func parseCommand(resp protocol.RESP) (string, []string) {
+	raw := protocol.Strings(resp)
+	if len(raw) == 0 {
+		return "", nil
 	}
+	return strings.ToLower(raw[0]), raw[1:]
 }
@@ -0,0 +1,272 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+type geoSearchOptions struct {
+	query     cache.GeoQuery
+	unit      float64
+	withDist  bool
+	withHash  bool
+	withCoord bool
+	storeDist bool
+}
+
+func (c *Client) handleGeoAdd(args []string) (string, error) {
+	var (
+		mode   string
+		nx, xx bool
+		ch     bool
+		i      = 1
+	)
+loop:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			mode, nx = "nx", true
+		case "xx":
+			mode, xx = "xx", true
+		case "ch":
+			ch = true
+		default:
+			break loop
+		}
+	}
+
+	if nx && xx {
+		return protocol.ErrorString("ERR XX and NX options at the same time are not compatible"), nil
+	}
+
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%3 != 0 {
+		return protocol.ErrorString("ERR syntax error"), nil
+	}
+
+	members := make([]cache.GeoMember, 0, len(rest)/3)
+	for j := 0; j < len(rest); j += 3 {
+		lon, lat, err := parseLonLat(rest[j], rest[j+1])
+		if err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		members = append(members, cache.GeoMember{Name: rest[j+2], Lon: lon, Lat: lat})
+	}
+
+	r, err := c.keyspace().GeoAdd(args[0], members, mode, ch)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleGeoPos(w *protocol.Writer, args []string) error {
+	r, err := c.keyspace().GeoPos(args[0], args[1:])
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteValue(r)
+	return nil
+}
+
+func (c *Client) handleGeoDist(args []string) (string, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return protocol.ErrorString("ERR wrong number of arguments for 'geodist' command"), nil
+	}
+
+	unit := 1.0
+	if len(args) == 4 {
+		var ok bool
+		if unit, ok = geoUnits[strings.ToLower(args[3])]; !ok {
+			return protocol.ErrorString(errGeoUnit.Error()), nil
+		}
+	}
+
+	dist, ok, err := c.keyspace().GeoDist(args[0], args[1], args[2])
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+	if !ok {
+		return c.reply(nil), nil
+	}
+
+	return protocol.BulkString(fmt.Sprintf("%.4f", dist/unit)), nil
+}
+
+func (c *Client) handleGeoHash(w *protocol.Writer, args []string) error {
+	r, err := c.keyspace().GeoHash(args[0], args[1:])
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteValue(r)
+	return nil
+}
+
+func (c *Client) handleGeoSearch(w *protocol.Writer, args []string) error {
+	opts, err := parseGeoSearch(args[1:], false)
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	res, err := c.keyspace().GeoSearch(args[0], opts.query)
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteArrayHeader(len(res))
+	for _, r := range res {
+		if !opts.withDist && !opts.withHash && !opts.withCoord {
+			w.WriteBulk(r.Name)
+			continue
+		}
+
+		item := []any{r.Name}
+		if opts.withDist {
+			item = append(item, fmt.Sprintf("%.4f", r.Dist/opts.unit))
+		}
+		if opts.withHash {
+			item = append(item, int(r.Hash))
+		}
+		if opts.withCoord {
+			item = append(item, []any{r.Lon, r.Lat})
+		}
+		w.WriteValue(item)
+	}
+	return nil
+}
+
+func (c *Client) handleGeoSearchStore(args []string) (string, error) {
+	opts, err := parseGeoSearch(args[2:], true)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	r, err := c.keyspace().GeoSearchStore(args[0], args[1], opts.query, opts.storeDist, opts.unit)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+// parseGeoSearch parses everything after the key(s) of GEOSEARCH and
+// GEOSEARCHSTORE. Distances in the returned query are converted to meters.
+func parseGeoSearch(args []string, store bool) (geoSearchOptions, error) {
+	opts := geoSearchOptions{unit: 1}
+	var fromMember, fromLonLat, byRadius, byBox bool
+
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		left := len(args) - i - 1
+
+		switch {
+		case arg == "frommember" && left >= 1:
+			opts.query.Member = args[i+1]
+			fromMember = true
+			i++
+		case arg == "fromlonlat" && left >= 2:
+			lon, lat, err := parseLonLat(args[i+1], args[i+2])
+			if err != nil {
+				return opts, err
+			}
+			opts.query.Lon, opts.query.Lat = lon, lat
+			fromLonLat = true
+			i += 2
+		case arg == "byradius" && left >= 2:
+			radius, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || radius < 0 {
+				return opts, errors.New("ERR need numeric radius")
+			}
+			unit, ok := geoUnits[strings.ToLower(args[i+2])]
+			if !ok {
+				return opts, errGeoUnit
+			}
+			opts.query.Radius = radius * unit
+			opts.unit = unit
+			byRadius = true
+			i += 2
+		case arg == "bybox" && left >= 3:
+			width, err1 := strconv.ParseFloat(args[i+1], 64)
+			height, err2 := strconv.ParseFloat(args[i+2], 64)
+			if err1 != nil || err2 != nil || width < 0 || height < 0 {
+				return opts, errors.New("ERR need numeric width and height")
+			}
+			unit, ok := geoUnits[strings.ToLower(args[i+3])]
+			if !ok {
+				return opts, errGeoUnit
+			}
+			opts.query.ByBox = true
+			opts.query.Width, opts.query.Height = width*unit, height*unit
+			opts.unit = unit
+			byBox = true
+			i += 3
+		case arg == "asc" || arg == "desc":
+			opts.query.Sort = arg
+		case arg == "count" && left >= 1:
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil || count <= 0 {
+				return opts, errors.New("ERR COUNT must be > 0")
+			}
+			opts.query.Count = count
+			i++
+			if i+1 < len(args) && strings.ToLower(args[i+1]) == "any" {
+				opts.query.Any = true
+				i++
+			}
+		case arg == "withdist" && !store:
+			opts.withDist = true
+		case arg == "withhash" && !store:
+			opts.withHash = true
+		case arg == "withcoord" && !store:
+			opts.withCoord = true
+		case arg == "storedist" && store:
+			opts.storeDist = true
+		default:
+			return opts, errSyntax
+		}
+	}
+
+	switch {
+	case fromMember == fromLonLat:
+		return opts, errors.New("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+	case byRadius == byBox:
+		return opts, errors.New("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+	}
+
+	return opts, nil
+}
+
+func parseLonLat(lonArg, latArg string) (float64, float64, error) {
+	lon, err := strconv.ParseFloat(lonArg, 64)
+	if err != nil {
+		return 0, 0, errors.New("ERR value is not a valid float")
+	}
+	lat, err := strconv.ParseFloat(latArg, 64)
+	if err != nil {
+		return 0, 0, errors.New("ERR value is not a valid float")
+	}
+
+	if lon < cache.GeoLonMin || lon > cache.GeoLonMax || lat < cache.GeoLatMin || lat > cache.GeoLatMax {
+		return 0, 0, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return lon, lat, nil
+}
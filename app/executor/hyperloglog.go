@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+func (c *Client) handlePFAdd(args []string) (string, error) {
+	r, err := c.keyspace().PFAdd(args[0], args[1:])
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handlePFCount(args []string) (string, error) {
+	r, err := c.keyspace().PFCount(args)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handlePFMerge(args []string) (string, error) {
+	if err := c.keyspace().PFMerge(args[0], args[1:]); err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.SimpleString("OK"), nil
+}
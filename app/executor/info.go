@@ -0,0 +1,271 @@
+package executor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// serverStats are the counters INFO reports, guarded by the executor lock.
+type serverStats struct {
+	startedAt time.Time
+	runID     string
+
+	connections int
+	commands    int
+	expiredKeys int
+	hits        int
+	misses      int
+	// evictedClients counts the clients closed for MaxMemoryClients.
+	evictedClients int
+
+	ops opsSampler
+}
+
+func newServerStats() serverStats {
+	id := make([]byte, 20)
+	_, _ = rand.Read(id)
+	return serverStats{startedAt: time.Now(), runID: hex.EncodeToString(id)}
+}
+
+// opsSampler records the number of commands processed every
+// opsSampleInterval, so that the recent rate can be worked out from the
+// oldest sample still in the ring.
+type opsSampler struct {
+	samples [16]opsSample
+	next    int
+}
+
+type opsSample struct {
+	at  time.Time
+	ops int
+}
+
+const opsSampleInterval = 100 * time.Millisecond
+
+// record takes a sample, unless the last one is too recent.
+func (s *opsSampler) record(now time.Time, ops int) {
+	last := s.samples[(s.next+len(s.samples)-1)%len(s.samples)]
+	if now.Sub(last.at) < opsSampleInterval {
+		return
+	}
+
+	s.samples[s.next] = opsSample{now, ops}
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+// rate is the number of commands per second since the oldest sample taken
+// within the span of the ring. It is 0 after that long without commands.
+func (s *opsSampler) rate(now time.Time, ops int) int {
+	span := time.Duration(len(s.samples)) * opsSampleInterval
+	var oldest *opsSample
+	for i := range s.samples {
+		sample := &s.samples[i]
+		if now.Sub(sample.at) <= span && (oldest == nil || sample.at.Before(oldest.at)) {
+			oldest = sample
+		}
+	}
+
+	if oldest == nil || !now.After(oldest.at) {
+		return 0
+	}
+	return int(float64(ops-oldest.ops) / now.Sub(oldest.at).Seconds())
+}
+
+// infoSections lists the INFO sections in the order they are reported.
+var infoSections = []struct {
+	name   string
+	fields func(e *Executor) []infoField
+}{
+	{"server", (*Executor).serverInfo},
+	{"clients", (*Executor).clientsInfo},
+	{"memory", (*Executor).memoryInfo},
+	{"persistence", (*Executor).persistenceInfo},
+	{"stats", (*Executor).statsInfo},
+	{"replication", (*Executor).replicationInfo},
+	{"keyspace", (*Executor).keyspaceInfo},
+}
+
+type infoField struct {
+	name  string
+	value any
+}
+
+// handleInfo implements INFO [section [section ...]]. Besides section names,
+// "default", "all" and "everything" select every section, as all of them are
+// default ones. Unknown sections are left out.
+func (c *Client) handleInfo(args []string) (string, error) {
+	if len(args) == 0 {
+		args = []string{"default"}
+	}
+
+	want := make(map[string]bool)
+	for _, arg := range args {
+		want[strings.ToLower(arg)] = true
+	}
+	all := want["default"] || want["all"] || want["everything"]
+
+	e := c.ex
+	var b strings.Builder
+	for _, s := range infoSections {
+		if !all && !want[s.name] {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", strings.ToUpper(s.name[:1])+s.name[1:])
+		for _, f := range s.fields(e) {
+			fmt.Fprintf(&b, "%s:%v\r\n", f.name, f.value)
+		}
+	}
+	return c.reply(protocol.VerbatimString{Format: "txt", Text: b.String()}), nil
+}
+
+func (e *Executor) serverInfo() []infoField {
+	uptime := time.Since(e.stats.startedAt)
+	return []infoField{
+		{"redis_version", serverVersion},
+		{"redis_mode", "standalone"},
+		{"os", runtime.GOOS + " " + runtime.GOARCH},
+		{"arch_bits", strconv.IntSize},
+		{"go_version", runtime.Version()},
+		{"process_id", os.Getpid()},
+		{"run_id", e.stats.runID},
+		{"uptime_in_seconds", int(uptime / time.Second)},
+		{"uptime_in_days", int(uptime / (24 * time.Hour))},
+	}
+}
+
+func (e *Executor) clientsInfo() []infoField {
+	var blocked, pubsub int
+	for _, c := range e.clients {
+		if c.blocked {
+			blocked++
+		}
+		if c.subscribed() {
+			pubsub++
+		}
+	}
+
+	return []infoField{
+		{"connected_clients", len(e.clients)},
+		{"blocked_clients", blocked},
+		{"pubsub_clients", pubsub},
+	}
+}
+
+func (e *Executor) memoryInfo() []infoField {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	used := e.usedMemory()
+	return []infoField{
+		{"used_memory", used},
+		{"used_memory_human", humanBytes(used)},
+		{"used_memory_rss", ms.Sys},
+		{"used_memory_rss_human", humanBytes(int(ms.Sys))},
+		{"used_memory_peak", e.peakMemory},
+		{"used_memory_peak_human", humanBytes(e.peakMemory)},
+		{"maxmemory", e.config.MaxMemory},
+		{"maxmemory_human", humanBytes(e.config.MaxMemory)},
+		{"maxmemory_policy", e.config.MaxMemoryPolicy},
+	}
+}
+
+// persistenceInfo reports that nothing is ever saved or loaded.
+func (e *Executor) persistenceInfo() []infoField {
+	return []infoField{
+		{"loading", 0},
+		{"rdb_bgsave_in_progress", 0},
+		{"rdb_last_save_time", e.stats.startedAt.Unix()},
+		{"aof_enabled", 0},
+		{"aof_rewrite_in_progress", 0},
+	}
+}
+
+func (e *Executor) statsInfo() []infoField {
+	return []infoField{
+		{"total_connections_received", e.stats.connections},
+		{"total_commands_processed", e.stats.commands},
+		{"instantaneous_ops_per_sec", e.stats.ops.rate(time.Now(), e.stats.commands)},
+		{"expired_keys", e.stats.expiredKeys},
+		{"evicted_keys", e.evictedKeys},
+		{"evicted_clients", e.stats.evictedClients},
+		{"keyspace_hits", e.stats.hits},
+		{"keyspace_misses", e.stats.misses},
+		{"pubsub_channels", len(e.channels)},
+		{"pubsub_patterns", len(e.patterns)},
+		{"pubsubshard_channels", len(e.shardChannels)},
+	}
+}
+
+// replicationInfo reports a master without replicas, as replication is not
+// supported.
+func (e *Executor) replicationInfo() []infoField {
+	return []infoField{
+		{"role", "master"},
+		{"connected_slaves", 0},
+		{"master_replid", e.stats.runID},
+		{"master_repl_offset", 0},
+	}
+}
+
+// keyspaceInfo lists the databases that have keys.
+func (e *Executor) keyspaceInfo() []infoField {
+	var fields []infoField
+	for i, db := range e.dbs {
+		keys := db.Len()
+		if keys == 0 {
+			continue
+		}
+
+		expires, ttl := db.ExpiringKeys()
+		fields = append(fields, infoField{
+			"db" + strconv.Itoa(i),
+			fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d", keys, expires, ttl.Milliseconds()),
+		})
+	}
+	return fields
+}
+
+// humanBytes formats n the way Redis shows memory in INFO, such as 1.50M.
+func humanBytes(n int) string {
+	units := []string{"K", "M", "G", "T", "P"}
+	if n < 1024 {
+		return strconv.Itoa(n) + "B"
+	}
+
+	f := float64(n) / 1024
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64) + units[i]
+}
+
+// countKeyLookups counts the keys of a read-only command as keyspace hits
+// or misses, depending on whether they exist before it runs.
+func (e *Executor) countKeyLookups(cmd command, c *Client, args []string) {
+	if cmd.flags&FlagReadonly == 0 || cmd.noTouch {
+		return
+	}
+
+	db := c.keyspace()
+	for _, key := range cmd.keyArgs(args) {
+		if db.Exists(key) {
+			e.stats.hits++
+		} else {
+			e.stats.misses++
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// latencyHistoryLen is how many samples are kept per event, as in Redis.
+const latencyHistoryLen = 160
+
+// The events the latency monitor records. Nothing is persisted, so there are
+// no fork or AOF events.
+const (
+	latencyCommand       = "command"
+	latencyFastCommand   = "fast-command"
+	latencyExpireDel     = "expire-del"
+	latencyEvictionCycle = "eviction-cycle"
+	latencyEvictionDel   = "eviction-del"
+)
+
+var latencyHelp = []string{
+	"DOCTOR",
+	"    Return a human readable latency analysis report.",
+	"HISTORY <event>",
+	"    Return time-latency samples for the <event> class.",
+	"LATEST",
+	"    Return the latest latency samples for all events.",
+	"RESET [<event> ...]",
+	"    Reset latency data of one or more <event> classes.",
+	"    (default: reset all data for all event classes)",
+}
+
+// latencyAdvice is what LATENCY DOCTOR suggests for each event.
+var latencyAdvice = map[string]string{
+	latencyCommand:       "- Check your Slow Log to understand what are the commands you are running which are too slow to execute. Please check https://redis.io/commands/slowlog for more information.",
+	latencyFastCommand:   "- The system is slow to execute Redis code paths not containing system calls. This usually means the system does not provide Redis CPU time to run for long periods. You should try to: 1) Lower the system load. 2) Use a computer / VM just for Redis if you are running other software in the same system. 3) Check if you have a \"noisy neighbour\" problem.",
+	latencyExpireDel:     "- Deleting expired keys is slow. Setting a lot of keys to expire at the same time makes them expire together: consider spreading their expire times.",
+	latencyEvictionCycle: "- Evicting keys to stay under maxmemory is slow. Consider raising maxmemory, or lowering maxmemory-samples.",
+	latencyEvictionDel:   "- Deleting evicted keys is slow, which usually means they are big. Consider raising maxmemory, or splitting big values.",
+}
+
+// latencyMonitor keeps the spikes above latency-monitor-threshold of each
+// event, guarded by the executor lock.
+type latencyMonitor map[string]*latencyEvent
+
+type latencyEvent struct {
+	// samples is a ring of at most one sample per second, with the highest
+	// latency of that second.
+	samples []latencySample
+	next    int
+	max     time.Duration
+}
+
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// latest is the most recent sample.
+func (ev *latencyEvent) latest() latencySample {
+	return ev.samples[(ev.next-1+len(ev.samples))%len(ev.samples)]
+}
+
+// history returns the samples, the oldest first.
+func (ev *latencyEvent) history() []latencySample {
+	return append(slices.Clone(ev.samples[ev.next:]), ev.samples[:ev.next]...)
+}
+
+// recordLatency adds a sample to event if the latency monitor is on and d
+// reaches its threshold.
+func (e *Executor) recordLatency(event string, d time.Duration) {
+	threshold := e.config.LatencyMonitorThreshold
+	if threshold <= 0 || d < threshold {
+		return
+	}
+
+	ev, ok := e.latency[event]
+	if !ok {
+		ev = &latencyEvent{}
+		e.latency[event] = ev
+	}
+	ev.max = max(ev.max, d)
+
+	now := time.Now().Truncate(time.Second)
+	if len(ev.samples) > 0 {
+		if last := ev.latest(); last.at.Equal(now) {
+			ev.samples[(ev.next-1+len(ev.samples))%len(ev.samples)].latency = max(last.latency, d)
+			return
+		}
+	}
+
+	if len(ev.samples) < latencyHistoryLen {
+		ev.samples = append(ev.samples, latencySample{now, d})
+		return
+	}
+	ev.samples[ev.next] = latencySample{now, d}
+	ev.next = (ev.next + 1) % len(ev.samples)
+}
+
+// handleLatency implements LATENCY LATEST, LATENCY HISTORY event, LATENCY
+// RESET [event [event ...]], LATENCY DOCTOR and LATENCY HELP. Latencies are in
+// milliseconds.
+func (c *Client) handleLatency(args []string) (string, error) {
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+	monitor := c.ex.latency
+
+	wrongArgs := protocol.ErrorString("ERR wrong number of arguments for 'latency|" + sub + "' command")
+
+	switch sub {
+	case "latest":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+
+		res := []any{}
+		for _, name := range slices.Sorted(maps.Keys(monitor)) {
+			ev := monitor[name]
+			latest := ev.latest()
+			res = append(res, []any{name, int(latest.at.Unix()), int(latest.latency.Milliseconds()), int(ev.max.Milliseconds())})
+		}
+		return c.reply(res), nil
+	case "history":
+		if len(args) != 1 {
+			return wrongArgs, nil
+		}
+
+		res := []any{}
+		if ev, ok := monitor[args[0]]; ok {
+			for _, s := range ev.history() {
+				res = append(res, []any{int(s.at.Unix()), int(s.latency.Milliseconds())})
+			}
+		}
+		return c.reply(res), nil
+	case "reset":
+		if len(args) == 0 {
+			n := len(monitor)
+			clear(monitor)
+			return protocol.Integer(n), nil
+		}
+
+		var n int
+		for _, name := range args {
+			if _, ok := monitor[name]; ok {
+				delete(monitor, name)
+				n++
+			}
+		}
+		return protocol.Integer(n), nil
+	case "doctor":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return c.reply(protocol.VerbatimString{Format: "txt", Text: c.ex.latencyDoctor()}), nil
+	case "help":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return helpReply("LATENCY", latencyHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try LATENCY HELP."), nil
+	}
+}
+
+// latencyDoctor is the report of LATENCY DOCTOR: statistics on the spikes of
+// each event, then advice on the events seen.
+func (e *Executor) latencyDoctor() string {
+	if e.config.LatencyMonitorThreshold <= 0 {
+		return "I'm sorry, Dave, I can't do that. Latency monitoring is disabled in this Redis instance. You may start it with --latency-monitor-threshold <milliseconds> in order to enable it. If we weren't in a deep space mission I'd suggest to take a look at https://redis.io/topics/latency-monitor.\n"
+	}
+	if len(e.latency) == 0 {
+		return "Dave, no latency spike was observed during the lifetime of this Redis instance, not in the slightest bit. I honestly think you ought to sleep tonight.\n"
+	}
+
+	var report strings.Builder
+	report.WriteString("Dave, I have observed latency spikes in this Redis instance. You don't mind talking about it, do you Dave?\n\n")
+
+	names := slices.Sorted(maps.Keys(e.latency))
+	for i, name := range names {
+		ev := e.latency[name]
+		samples := ev.history()
+
+		var sum time.Duration
+		for _, s := range samples {
+			sum += s.latency
+		}
+		avg := sum / time.Duration(len(samples))
+
+		var dev time.Duration
+		for _, s := range samples {
+			dev += max(s.latency-avg, avg-s.latency)
+		}
+		dev /= time.Duration(len(samples))
+
+		period := samples[len(samples)-1].at.Sub(samples[0].at).Seconds() / float64(len(samples))
+		fmt.Fprintf(&report, "%d. %s: %d latency spikes (average %dms, mean deviation %dms, period %.2f sec). Worst all time event %dms.\n",
+			i+1, name, len(samples), avg.Milliseconds(), dev.Milliseconds(), period, ev.max.Milliseconds())
+	}
+
+	report.WriteString("\nI have a few advices for you:\n\n")
+	for _, name := range names {
+		report.WriteString(latencyAdvice[name] + "\n")
+	}
+	return report.String()
+}
@@ -4,107 +4,105 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/codecrafters-io/redis-starter-go/app/cache"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 )
 
-func handleRPush(args []string) (string, error) {
-	if len(args) < 2 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'rpush' command"), nil
-	}
-
+func (c *Client) handleRPush(args []string) (string, error) {
 	anyArgs := make([]any, len(args[1:]))
 	for i, a := range args[1:] {
 		anyArgs[i] = a
 	}
 
-	r := cache.RPush(args[0], anyArgs)
+	r := c.keyspace().RPush(args[0], anyArgs)
 	return protocol.Integer(r), nil
 }
 
-func handleLPush(args []string) (string, error) {
-	if len(args) < 2 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'lpush' command"), nil
-	}
-
+func (c *Client) handleLPush(args []string) (string, error) {
 	otherArgs := args[1:]
 	anyArgs := make([]any, len(otherArgs))
 	for i, a := range otherArgs {
 		anyArgs[len(otherArgs)-i-1] = a
 	}
 
-	r := cache.LPush(args[0], anyArgs)
+	r := c.keyspace().LPush(args[0], anyArgs)
 	return protocol.Integer(r), nil
 }
 
-func handleLRange(args []string) (string, error) {
-	if len(args) < 3 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'lrange' command"), nil
-	}
-
+func (c *Client) handleLRange(w *protocol.Writer, args []string) error {
 	start, err := strconv.Atoi(strings.TrimSpace(args[1]))
 	if err != nil {
-		return protocol.ErrorString("ERR invalid start argument for 'lrange' command"), nil
+		w.WriteError("ERR invalid start argument for 'lrange' command")
+		return nil
 	}
 	end, err := strconv.Atoi(strings.TrimSpace(args[2]))
 	if err != nil {
-		return protocol.ErrorString("ERR invalid end argument for 'lrange' command"), nil
+		w.WriteError("ERR invalid end argument for 'lrange' command")
+		return nil
 	}
 
-	r := cache.LRange(args[0], start, end)
-	if len(r) == 0 {
-		return protocol.Array([]any{}), nil
+	r := c.keyspace().LRange(args[0], start, end)
+	w.WriteArrayHeader(len(r))
+	for _, v := range r {
+		w.WriteBulk(v.(string))
 	}
-
-	return protocol.Array(r), nil
+	return nil
 }
 
-func handleLLen(args []string) (string, error) {
-	if len(args) < 1 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'llen' command"), nil
-	}
-	r := cache.LLen(args[0])
+func (c *Client) handleLLen(args []string) (string, error) {
+	r := c.keyspace().LLen(args[0])
 	return protocol.Integer(r), nil
 }
 
-func handleRPop(args []string) (string, error) {
-	if len(args) < 1 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'rpop' command"), nil
-	}
-
+func (c *Client) handleRPop(w *protocol.Writer, args []string) error {
 	idx, err := extractPopArgs(args)
 	if err != nil {
-		return protocol.ErrorString("ERR invalid index argument for 'rpop' command"), nil
-	}
-
-	r := cache.RPop(args[0], idx)
-	switch r.(type) {
+		w.WriteError("ERR invalid index argument for 'rpop' command")
+		return nil
+	}
+
+	r := c.keyspace().RPop(args[0], idx)
+	switch r := r.(type) {
+	case nil:
+		if idx != nil {
+			w.WriteNullArray()
+		} else {
+			w.WriteNull()
+		}
 	case string:
-		return protocol.BulkString(r.(string)), nil
-	default:
-		d, _ := r.([]any)
-		return protocol.Array(d), nil
-	}
+		w.WriteBulk(r)
+	case []any:
+		w.WriteArrayHeader(len(r))
+		for _, v := range r {
+			w.WriteBulk(v.(string))
+		}
+	}
+	return nil
 }
 
-func handleLPop(args []string) (string, error) {
-	if len(args) < 1 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'lpop' command"), nil
-	}
-
+func (c *Client) handleLPop(w *protocol.Writer, args []string) error {
 	idx, err := extractPopArgs(args)
 	if err != nil {
-		return protocol.ErrorString("ERR invalid index argument for 'lpop' command"), nil
-	}
-
-	r := cache.LPop(args[0], idx)
-	switch r.(type) {
+		w.WriteError("ERR invalid index argument for 'lpop' command")
+		return nil
+	}
+
+	r := c.keyspace().LPop(args[0], idx)
+	switch r := r.(type) {
+	case nil:
+		if idx != nil {
+			w.WriteNullArray()
+		} else {
+			w.WriteNull()
+		}
 	case string:
-		return protocol.BulkString(r.(string)), nil
-	default:
-		d, _ := r.([]any)
-		return protocol.Array(d), nil
-	}
+		w.WriteBulk(r)
+	case []any:
+		w.WriteArrayHeader(len(r))
+		for _, v := range r {
+			w.WriteBulk(v.(string))
+		}
+	}
+	return nil
 }
 
 func extractPopArgs(args []string) (*int, error) {
@@ -121,23 +119,27 @@ func extractPopArgs(args []string) (*int, error) {
 	return idx, err
 }
 
-func handleBLPop(args []string) (string, error) {
-	if len(args) < 2 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'blpop' command"), nil
-	}
-
+func (c *Client) handleBLPop(args []string) (string, error) {
 	timeout, err := strconv.ParseFloat(args[1], 64)
 	if err != nil {
 		return protocol.ErrorString("ERR invalid timeout argument for 'blpop' command"), nil
 	}
 
-	blChan := cache.BLPop(args[0], timeout)
-	r := <-blChan
+	// Inside EXEC a blocking pop cannot wait, so it behaves as if it timed out
+	// when the list is empty.
+	var r any
+	if !c.ex.inExec {
+		wait, cancel := c.keyspace().BLPop(args[0], timeout)
+		r = await(c, wait, cancel)
+	} else if v := c.keyspace().LPop(args[0], nil); v != nil {
+		r = []any{args[0], v}
+	}
 	switch r.(type) {
-	case string:
-		return protocol.BulkString(r.(string)), nil
+	case nil:
+		// Timed out.
+		return c.nullArrayReply(), nil
 	default:
 		d, _ := r.([]any)
-		return protocol.Array(d), nil
+		return c.reply(d), nil
 	}
 }
@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+var errOOM = protocol.ErrorString("OOM command not allowed when used memory > 'maxmemory'.")
+
+// EvictionPolicy is how keys are picked for eviction once the used memory
+// exceeds maxmemory.
+type EvictionPolicy int
+
+const (
+	// NoEviction rejects commands that may use more memory instead.
+	NoEviction EvictionPolicy = iota
+	AllKeysLRU
+	AllKeysLFU
+	AllKeysRandom
+	VolatileLRU
+	VolatileLFU
+	VolatileRandom
+	VolatileTTL
+)
+
+var evictionPolicyNames = []string{
+	NoEviction:     "noeviction",
+	AllKeysLRU:     "allkeys-lru",
+	AllKeysLFU:     "allkeys-lfu",
+	AllKeysRandom:  "allkeys-random",
+	VolatileLRU:    "volatile-lru",
+	VolatileLFU:    "volatile-lfu",
+	VolatileRandom: "volatile-random",
+	VolatileTTL:    "volatile-ttl",
+}
+
+// ParseEvictionPolicy parses a policy by its maxmemory-policy name.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	for p, n := range evictionPolicyNames {
+		if n == name {
+			return EvictionPolicy(p), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown eviction policy %q", name)
+}
+
+func (p EvictionPolicy) String() string {
+	return evictionPolicyNames[p]
+}
+
+// volatile reports whether only keys with an expiry may be evicted.
+func (p EvictionPolicy) volatile() bool {
+	return p >= VolatileLRU
+}
+
+// before reports whether a is a better candidate for eviction than b.
+func (p EvictionPolicy) before(a, b cache.KeyStats) bool {
+	switch p {
+	case AllKeysLFU, VolatileLFU:
+		if a.Freq != b.Freq {
+			return a.Freq < b.Freq
+		}
+		return a.LastAccess.Before(b.LastAccess)
+	case VolatileTTL:
+		return a.ExpiresAt.Before(b.ExpiresAt)
+	default:
+		return a.LastAccess.Before(b.LastAccess)
+	}
+}
+
+// usedMemory is the estimated memory used by all databases. It keeps track
+// of the peak as well.
+func (e *Executor) usedMemory() int {
+	var n int
+	for _, db := range e.dbs {
+		n += db.UsedMemory()
+	}
+	e.peakMemory = max(e.peakMemory, n)
+	return n
+}
+
+// freeMemory evicts keys until the used memory is back under maxmemory,
+// reporting whether it got there.
+func (e *Executor) freeMemory() bool {
+	if e.config.MaxMemory <= 0 {
+		return true
+	}
+
+	start := time.Now()
+	evicted := e.evictedKeys
+	defer func() {
+		if e.evictedKeys > evicted {
+			e.recordLatency(latencyEvictionCycle, time.Since(start))
+		}
+	}()
+
+	for e.usedMemory() > e.config.MaxMemory {
+		if e.config.MaxMemoryPolicy == NoEviction {
+			return false
+		}
+
+		db, key, ok := e.evictionCandidate()
+		if !ok {
+			return false
+		}
+
+		delStart := time.Now()
+		db.Remove(key)
+		e.evictedKeys++
+		e.recordLatency(latencyEvictionDel, time.Since(delStart))
+	}
+	return true
+}
+
+// evictClients disconnects the clients holding the most replies until those
+// of all clients fit in MaxMemoryClients. Clients that ran CLIENT NO-EVICT ON
+// are spared. The executor lock must be held.
+func (e *Executor) evictClients() {
+	if e.config.MaxMemoryClients <= 0 {
+		return
+	}
+
+	type candidate struct {
+		c   *Client
+		mem int
+	}
+
+	var (
+		total      int
+		candidates []candidate
+	)
+	for _, c := range e.clients {
+		mem := c.outputMemory()
+		total += mem
+		if !c.noEvict && mem > 0 {
+			candidates = append(candidates, candidate{c, mem})
+		}
+	}
+	if total <= e.config.MaxMemoryClients {
+		return
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int { return cmp.Compare(b.mem, a.mem) })
+	for _, cand := range candidates {
+		if total <= e.config.MaxMemoryClients {
+			break
+		}
+
+		log.Printf("Evicting client id=%d addr=%s using %d bytes of output.", cand.c.id, cand.c.addr, cand.mem)
+		cand.c.close()
+		total -= cand.mem
+		e.stats.evictedClients++
+	}
+}
+
+// evictionCandidate samples keys from every database and picks the best one
+// to evict according to the policy.
+func (e *Executor) evictionCandidate() (cache.Cache, string, bool) {
+	type candidate struct {
+		db    cache.Cache
+		stats cache.KeyStats
+	}
+
+	policy := e.config.MaxMemoryPolicy
+	var candidates []candidate
+	for _, db := range e.dbs {
+		for _, stats := range db.Sample(e.config.MaxMemorySamples, policy.volatile()) {
+			candidates = append(candidates, candidate{db, stats})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+
+	best := candidates[rand.IntN(len(candidates))]
+	if policy != AllKeysRandom && policy != VolatileRandom {
+		for _, c := range candidates {
+			if policy.before(c.stats, best.stats) {
+				best = c
+			}
+		}
+	}
+	return best.db, best.stats.Key, true
+}
+
+// deniedOOM reports whether cmd must be rejected while the used memory
+// exceeds maxmemory: commands that may grow it, and EXEC when such commands
+// are queued.
+func (c *Client) deniedOOM(cmd command) bool {
+	if cmd.flags&FlagDenyOOM != 0 {
+		return true
+	}
+	if cmd.name == "exec" {
+		for _, queued := range c.tx.queued {
+			if cmd, _ := c.ex.commands.get(queued[0]); cmd.flags&FlagDenyOOM != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
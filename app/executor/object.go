@@ -0,0 +1,226 @@
+package executor
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// sharedIntegers is how many small integers Redis shares between keys rather
+// than allocating, which OBJECT REFCOUNT reports with sharedRefCount.
+const (
+	sharedIntegers = 10000
+	sharedRefCount = 2147483647
+)
+
+var (
+	objectHelp = []string{
+		"ENCODING <key>",
+		"    Return the kind of internal representation used in order to store the value",
+		"    associated with a <key>.",
+		"FREQ <key>",
+		"    Return the access frequency index of the <key>. The returned integer is",
+		"    proportional to the logarithm of the recent access frequency of the key.",
+		"IDLETIME <key>",
+		"    Return the idle time of the <key>, that is the approximated number of",
+		"    seconds elapsed since the last access to the key.",
+		"REFCOUNT <key>",
+		"    Return the number of references of the value associated with the specified",
+		"    <key>.",
+	}
+
+	memoryHelp = []string{
+		"DOCTOR",
+		"    Return memory problems reports.",
+		"MALLOC-STATS",
+		"    Return internal statistics report from the memory allocator.",
+		"PURGE",
+		"    Attempt to purge dirty pages for reclamation by the allocator.",
+		"STATS",
+		"    Return information about the memory usage of the server.",
+		"USAGE <key> [SAMPLES <count>]",
+		"    Return memory in bytes used by <key> and its value. Nested values are",
+		"    sampled up to <count> times (default: 5, 0 means sample all).",
+	}
+)
+
+// lfu reports whether the policy evicts by access frequency.
+func (p EvictionPolicy) lfu() bool {
+	return p == AllKeysLFU || p == VolatileLFU
+}
+
+// handleObject implements OBJECT ENCODING|IDLETIME|FREQ|REFCOUNT key and
+// OBJECT HELP. Like Redis, IDLETIME is only available under LRU policies and
+// FREQ under LFU ones, even though both are tracked.
+func (c *Client) handleObject(args []string) (string, error) {
+	sub := strings.ToLower(args[0])
+	if sub == "help" && len(args) == 1 {
+		return helpReply("OBJECT", objectHelp...), nil
+	}
+	if len(args) != 2 {
+		return protocol.ErrorString("ERR unknown subcommand or wrong number of arguments for '" + args[0] + "'. Try OBJECT HELP."), nil
+	}
+
+	ks := c.keyspace()
+	key := args[1]
+	stats, ok := ks.Stats(key)
+	if !ok {
+		return c.reply(nil), nil
+	}
+	policy := c.ex.config.MaxMemoryPolicy
+
+	switch sub {
+	case "encoding":
+		enc, _ := ks.Encoding(key)
+		return protocol.BulkString(enc), nil
+	case "idletime":
+		if policy.lfu() {
+			return protocol.ErrorString("ERR An LFU maxmemory policy is selected, idle time not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."), nil
+		}
+		return protocol.Integer(int(time.Since(stats.LastAccess) / time.Second)), nil
+	case "freq":
+		if !policy.lfu() {
+			return protocol.ErrorString("ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."), nil
+		}
+		return protocol.Integer(stats.Freq), nil
+	case "refcount":
+		if enc, _ := ks.Encoding(key); enc == "int" {
+			v, _ := ks.Get(key)
+			if n, _ := strconv.Atoi(v.(string)); n >= 0 && n < sharedIntegers {
+				return protocol.Integer(sharedRefCount), nil
+			}
+		}
+		return protocol.Integer(1), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand or wrong number of arguments for '" + args[0] + "'. Try OBJECT HELP."), nil
+	}
+}
+
+// handleMemory implements MEMORY USAGE key [SAMPLES count], MEMORY STATS,
+// MEMORY DOCTOR, MEMORY MALLOC-STATS, MEMORY PURGE and MEMORY HELP. Sizes are
+// the estimates the eviction policies work with.
+func (c *Client) handleMemory(args []string) (string, error) {
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	wrongArgs := protocol.ErrorString("ERR wrong number of arguments for 'memory|" + sub + "' command")
+
+	switch sub {
+	case "usage":
+		if len(args) == 0 {
+			return wrongArgs, nil
+		}
+		return c.handleMemoryUsage(args)
+	case "stats":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return c.reply(c.ex.memoryStats()), nil
+	case "doctor":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return c.reply(protocol.VerbatimString{Format: "txt", Text: c.ex.memoryDoctor()}), nil
+	case "malloc-stats":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return protocol.BulkString("Stats not supported for the current allocator"), nil
+	case "purge":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		debug.FreeOSMemory()
+		return protocol.SimpleString("OK"), nil
+	case "help":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return helpReply("MEMORY", memoryHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try MEMORY HELP."), nil
+	}
+}
+
+func (c *Client) handleMemoryUsage(args []string) (string, error) {
+	samples := cache.DefaultSamples
+	if len(args) > 1 {
+		if len(args) != 3 || strings.ToLower(args[1]) != "samples" {
+			return protocol.ErrorString(errSyntax.Error()), nil
+		}
+
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return protocol.ErrorString("ERR value is not an integer or out of range"), nil
+		}
+		samples = n
+	}
+
+	n, ok := c.keyspace().MemoryUsage(args[0], samples)
+	if !ok {
+		return c.reply(nil), nil
+	}
+	return protocol.Integer(n), nil
+}
+
+// memoryStats is the MEMORY STATS reply. The allocator figures come from the
+// Go runtime; everything else is estimated like the used memory.
+func (e *Executor) memoryStats() protocol.Map {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	used := e.usedMemory()
+	var keys int
+	for _, db := range e.dbs {
+		keys += db.Len()
+	}
+
+	var bytesPerKey int
+	if keys > 0 {
+		bytesPerKey = used / keys
+	}
+	peakPercentage := 100.0
+	if e.peakMemory > 0 {
+		peakPercentage = float64(used) * 100 / float64(e.peakMemory)
+	}
+
+	return protocol.Map{
+		"peak.allocated", e.peakMemory,
+		"total.allocated", used,
+		"startup.allocated", 0,
+		"keys.count", keys,
+		"keys.bytes-per-key", bytesPerKey,
+		"dataset.bytes", used,
+		"dataset.percentage", 100.0,
+		"peak.percentage", peakPercentage,
+		"allocator.allocated", int(ms.HeapAlloc),
+		"allocator.active", int(ms.HeapInuse),
+		"allocator.resident", int(ms.Sys),
+	}
+}
+
+// memoryDoctor reports the memory issues MEMORY DOCTOR looks for that apply
+// here: a peak well above the current usage and eviction under pressure.
+func (e *Executor) memoryDoctor() string {
+	used := e.usedMemory()
+	if used < 5<<20 {
+		return "Hi Sam, this instance is empty or is using very little memory, my issues detector can't be used in these conditions. Please, leave for your mission on Earth and fill it with some data. The new Sam and I will be back to our programming as soon as I finished rebooting.\n"
+	}
+
+	var issues []string
+	if e.peakMemory > used*3/2 {
+		issues = append(issues, " * Peak memory: In the past this instance used more than 150% the memory that is currently using. The allocator is normally not able to release memory after a peak, so you can expect to see a big fragmentation ratio, however this is actually harmless and is only due to the memory peak, and if the Redis instance Resident Set Size (RSS) is currently bigger than expected, the memory will be used as soon as you fill the Redis instance with more data. If the memory peak was only occasional and you want to try to reclaim memory, please try the MEMORY PURGE command, otherwise the only other option is to shutdown and restart the instance.\n")
+	}
+	if e.config.MaxMemory > 0 && e.evictedKeys > 0 {
+		issues = append(issues, " * Eviction: "+strconv.Itoa(e.evictedKeys)+" keys were evicted to stay under maxmemory. Consider raising maxmemory if these keys are still needed.\n")
+	}
+	if len(issues) == 0 {
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base.\n"
+	}
+	return "Sam, I detected a few issues in this Redis instance memory implants:\n\n" + strings.Join(issues, "\n") + "\nI'm here to keep you safe, Sam. I want to help you.\n"
+}
@@ -0,0 +1,193 @@
+package executor
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// closeTimeout bounds the time left to write the last replies of a client
+// that is closed.
+const closeTimeout = time.Second
+
+// OutputBufferLimit bounds the replies a client has yet to read. A client is
+// disconnected once more than Hard bytes are waiting for it, or more than
+// Soft bytes for SoftFor in a row. Zero limits don't apply.
+type OutputBufferLimit struct {
+	Hard    int
+	Soft    int
+	SoftFor time.Duration
+}
+
+// DefaultPubSubOutputLimit is the output limit of subscribed clients, which
+// would otherwise let a slow subscriber hoard every message published.
+var DefaultPubSubOutputLimit = OutputBufferLimit{Hard: 32 << 20, Soft: 8 << 20, SoftFor: time.Minute}
+
+// output queues a client's encoded replies for its writer goroutine, which
+// writes them to the connection without the executor lock, so that a client
+// which stops reading only holds up itself.
+type output struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// pending holds the bytes the writer has yet to pick up. queued and sent
+	// count the bytes ever queued and written, so that Flush can wait for its
+	// own replies.
+	pending      []byte
+	queued, sent int
+	// err is the write error that stopped the writer.
+	err error
+
+	// wake tells the writer there is something pending.
+	wake chan struct{}
+}
+
+func newOutput() *output {
+	o := &output{wake: make(chan struct{}, 1)}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// Write queues p for the writer. It never blocks on the connection: write
+// errors are reported by wait instead.
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	if o.err == nil {
+		o.pending = append(o.pending, p...)
+		o.queued += len(p)
+	}
+	o.mu.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// backlog is the number of bytes queued but not written yet.
+func (o *output) backlog() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.queued - o.sent
+}
+
+// mark returns the count of bytes queued so far, for wait.
+func (o *output) mark() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.queued
+}
+
+// wait blocks until the first n bytes queued have been written, returning the
+// error that stopped the writer if they can't be.
+func (o *output) wait(n int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.sent < n && o.err == nil {
+		o.cond.Wait()
+	}
+	if o.sent >= n {
+		return nil
+	}
+	return o.err
+}
+
+// stop fails the writes still pending with err.
+func (o *output) stop(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.err == nil {
+		o.err = err
+	}
+	o.pending = nil
+	o.cond.Broadcast()
+}
+
+// run writes the queued bytes to conn until a write fails or done is closed,
+// then closes conn. The replies queued by then are still written, within the
+// write deadline set by Client.close.
+func (o *output) run(conn net.Conn, done <-chan struct{}) {
+	defer func() {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Println(err)
+		}
+	}()
+
+	var buf []byte
+	for {
+		var closing bool
+		select {
+		case <-o.wake:
+		case <-done:
+			closing = true
+		}
+
+		o.mu.Lock()
+		buf, o.pending = o.pending, buf[:0]
+		o.mu.Unlock()
+
+		if len(buf) > 0 {
+			if _, err := conn.Write(buf); err != nil {
+				o.stop(err)
+				return
+			}
+
+			o.mu.Lock()
+			o.sent += len(buf)
+			o.cond.Broadcast()
+			o.mu.Unlock()
+		}
+
+		if closing {
+			o.stop(net.ErrClosed)
+			return
+		}
+	}
+}
+
+// outputLimit is the limit that applies to the client: subscribed clients
+// have their own.
+func (c *Client) outputLimit() OutputBufferLimit {
+	if c.subscribed() {
+		return c.ex.config.PubSubOutputLimit
+	}
+	return c.ex.config.NormalOutputLimit
+}
+
+// outputMemory is the size of the replies waiting to be read by the client.
+func (c *Client) outputMemory() int {
+	return c.out.Buffered() + c.output.backlog()
+}
+
+// checkOutputLimit disconnects the client if it has fallen too far behind
+// reading its replies, reporting whether it did. The executor lock must be
+// held.
+func (c *Client) checkOutputLimit() bool {
+	limit := c.outputLimit()
+	n := c.outputMemory()
+
+	switch {
+	case limit.Hard > 0 && n > limit.Hard:
+	case limit.Soft > 0 && n > limit.Soft:
+		if c.softLimitSince.IsZero() {
+			c.softLimitSince = time.Now()
+		}
+		if time.Since(c.softLimitSince) < limit.SoftFor {
+			return false
+		}
+	default:
+		c.softLimitSince = time.Time{}
+		return false
+	}
+
+	log.Printf("Client id=%d addr=%s closed for overcoming of output buffer limits.", c.id, c.addr)
+	c.close()
+	return true
+}
@@ -0,0 +1,316 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// subscriptions maps a channel, or a pattern, to the clients subscribed to
+// it. Names without subscribers are removed.
+type subscriptions map[string]map[*Client]struct{}
+
+func (s subscriptions) add(name string, c *Client) {
+	if s[name] == nil {
+		s[name] = make(map[*Client]struct{})
+	}
+	s[name][c] = struct{}{}
+}
+
+func (s subscriptions) remove(name string, c *Client) {
+	delete(s[name], c)
+	if len(s[name]) == 0 {
+		delete(s, name)
+	}
+}
+
+func (s subscriptions) names() []string {
+	return slices.Collect(maps.Keys(s))
+}
+
+// shardSubscriptions holds the shard channels by key slot, so that a slot's
+// subscribers can be found, and moved, along with its keys.
+type shardSubscriptions map[uint16]subscriptions
+
+func (s shardSubscriptions) add(name string, c *Client) {
+	slot := keySlot(name)
+	if s[slot] == nil {
+		s[slot] = make(subscriptions)
+	}
+	s[slot].add(name, c)
+}
+
+func (s shardSubscriptions) remove(name string, c *Client) {
+	slot := keySlot(name)
+	s[slot].remove(name, c)
+	if len(s[slot]) == 0 {
+		delete(s, slot)
+	}
+}
+
+func (s shardSubscriptions) subscribers(name string) map[*Client]struct{} {
+	return s[keySlot(name)][name]
+}
+
+func (s shardSubscriptions) names() []string {
+	var res []string
+	for _, subs := range s {
+		res = append(res, subs.names()...)
+	}
+	return res
+}
+
+// registry is a set of subscriptions clients can be added to and removed
+// from by name.
+type registry interface {
+	add(name string, c *Client)
+	remove(name string, c *Client)
+}
+
+var (
+	// subscribedModeCommands are the only commands a RESP2 client may run
+	// while it has subscriptions, since its replies share the connection with
+	// the messages.
+	subscribedModeCommands = []string{
+		"subscribe", "psubscribe", "ssubscribe",
+		"unsubscribe", "punsubscribe", "sunsubscribe",
+		"ping", "reset", "quit",
+	}
+)
+
+var pubSubHelp = []string{
+	"CHANNELS [<pattern>]",
+	"    Return the currently active channels matching a <pattern> (default: '*').",
+	"NUMPAT",
+	"    Return number of subscriptions to patterns.",
+	"NUMSUB [<channel> ...]",
+	"    Return the number of subscribers for the specified channels, excluding",
+	"    pattern subscriptions(default: no channels).",
+	"SHARDCHANNELS [<pattern>]",
+	"    Return the currently active shard level channels matching a <pattern> (default: '*').",
+	"SHARDNUMSUB [<shardchannel> ...]",
+	"    Return the number of subscribers for the specified shard level channel(s)",
+}
+
+func errSubscribedMode(name string) string {
+	return protocol.ErrorString(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name))
+}
+
+// subscribed reports whether the client is in subscribed mode.
+func (c *Client) subscribed() bool {
+	return c.subscriptionCount() > 0 || c.shardSubscriptionCount() > 0
+}
+
+// subscriptionCount is the count (P)SUBSCRIBE and (P)UNSUBSCRIBE reply with.
+// Shard channels are counted apart.
+func (c *Client) subscriptionCount() int {
+	return len(c.channels) + len(c.patterns)
+}
+
+func (c *Client) shardSubscriptionCount() int {
+	return len(c.shardChannels)
+}
+
+func (c *Client) handleSubscribe(args []string) (string, error) {
+	c.subscribe("subscribe", c.ex.channels, &c.channels, c.subscriptionCount, args)
+	return "", nil
+}
+
+func (c *Client) handlePSubscribe(args []string) (string, error) {
+	c.subscribe("psubscribe", c.ex.patterns, &c.patterns, c.subscriptionCount, args)
+	return "", nil
+}
+
+func (c *Client) handleUnsubscribe(args []string) (string, error) {
+	c.unsubscribe("unsubscribe", c.ex.channels, c.channels, c.subscriptionCount, args)
+	return "", nil
+}
+
+func (c *Client) handlePUnsubscribe(args []string) (string, error) {
+	c.unsubscribe("punsubscribe", c.ex.patterns, c.patterns, c.subscriptionCount, args)
+	return "", nil
+}
+
+func (c *Client) handleSSubscribe(args []string) (string, error) {
+	c.subscribe("ssubscribe", c.ex.shardChannels, &c.shardChannels, c.shardSubscriptionCount, args)
+	return "", nil
+}
+
+func (c *Client) handleSUnsubscribe(args []string) (string, error) {
+	c.unsubscribe("sunsubscribe", c.ex.shardChannels, c.shardChannels, c.shardSubscriptionCount, args)
+	return "", nil
+}
+
+// subscribe adds the client to reg under every name and confirms each one
+// with a push carrying count(). The confirmations are written straight away
+// rather than returned, so that no message published meanwhile can overtake
+// them.
+func (c *Client) subscribe(kind string, reg registry, mine *map[string]struct{}, count func() int, names []string) {
+	if *mine == nil {
+		*mine = make(map[string]struct{})
+	}
+
+	for _, name := range names {
+		if _, ok := (*mine)[name]; !ok {
+			(*mine)[name] = struct{}{}
+			reg.add(name, c)
+		}
+		c.push(kind, name, count())
+	}
+}
+
+// unsubscribe removes the client from names, or from everything in mine when
+// names is empty, confirming each with a push.
+func (c *Client) unsubscribe(kind string, reg registry, mine map[string]struct{}, count func() int, names []string) {
+	if len(names) == 0 {
+		for name := range mine {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	if len(names) == 0 {
+		c.push(kind, nil, count())
+		return
+	}
+
+	for _, name := range names {
+		if _, ok := mine[name]; ok {
+			delete(mine, name)
+			reg.remove(name, c)
+		}
+		c.push(kind, name, count())
+	}
+}
+
+// unsubscribeAll drops every subscription without confirming them, for a
+// client that is going away.
+func (c *Client) unsubscribeAll() {
+	for name := range c.channels {
+		c.ex.channels.remove(name, c)
+	}
+	for name := range c.patterns {
+		c.ex.patterns.remove(name, c)
+	}
+	for name := range c.shardChannels {
+		c.ex.shardChannels.remove(name, c)
+	}
+	c.channels, c.patterns, c.shardChannels = nil, nil, nil
+}
+
+func (c *Client) handlePublish(args []string) (string, error) {
+	return protocol.Integer(c.ex.publish(args[0], args[1])), nil
+}
+
+// publish delivers message to the subscribers of channel and of every
+// pattern matching it, returning how many deliveries were made.
+func (e *Executor) publish(channel, message string) int {
+	var n int
+	for c := range e.channels[channel] {
+		c.deliver("message", channel, message)
+		n++
+	}
+
+	for pattern, subs := range e.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		for c := range subs {
+			c.deliver("pmessage", pattern, channel, message)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Client) handleSPublish(args []string) (string, error) {
+	var n int
+	for sub := range c.ex.shardChannels.subscribers(args[0]) {
+		sub.deliver("smessage", args[0], args[1])
+		n++
+	}
+	return protocol.Integer(n), nil
+}
+
+func (c *Client) handlePubSub(args []string) (string, error) {
+	ex := c.ex
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "channels":
+		if len(args) > 1 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'pubsub|channels' command"), nil
+		}
+		return protocol.Array(activeNames(ex.channels.names(), args)), nil
+	case "numsub":
+		res := make([]any, 0, 2*len(args))
+		for _, name := range args {
+			res = append(res, name, len(ex.channels[name]))
+		}
+		return protocol.Array(res), nil
+	case "numpat":
+		if len(args) != 0 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'pubsub|numpat' command"), nil
+		}
+		return protocol.Integer(len(ex.patterns)), nil
+	case "shardchannels":
+		if len(args) > 1 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'pubsub|shardchannels' command"), nil
+		}
+		return protocol.Array(activeNames(ex.shardChannels.names(), args)), nil
+	case "shardnumsub":
+		res := make([]any, 0, 2*len(args))
+		for _, name := range args {
+			res = append(res, name, len(ex.shardChannels.subscribers(name)))
+		}
+		return protocol.Array(res), nil
+	case "help":
+		if len(args) != 0 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'pubsub|help' command"), nil
+		}
+		return helpReply("PUBSUB", pubSubHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try PUBSUB HELP."), nil
+	}
+}
+
+// activeNames sorts names, filtered by the glob pattern in args if any.
+func activeNames(names []string, args []string) []any {
+	slices.Sort(names)
+
+	res := make([]any, 0, len(names))
+	for _, name := range names {
+		if len(args) == 0 || globMatch(args[0], name) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// push sends an out-of-band frame to the client: a push in RESP3, a plain
+// array in RESP2. The executor lock must be held. The frame is handed to the
+// client's writer right away, since the client may be idle waiting for it,
+// but it is never written from here: a subscriber that does not read holds up
+// no publisher.
+func (c *Client) push(items ...any) {
+	c.out.WritePushHeader(len(items))
+	for _, v := range items {
+		c.out.WriteValue(v)
+	}
+
+	if err := c.out.Flush(); err != nil {
+		log.Println(err)
+	}
+}
+
+// deliver pushes a published message to a subscriber, which is disconnected
+// rather than left to hoard messages once it is over its output limit.
+func (c *Client) deliver(items ...any) {
+	c.push(items...)
+	c.checkOutputLimit()
+}
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// Context is what a registered command runs with. The command holds the
+// executor lock while it runs, so it must not keep Client or Reply around
+// after returning.
+type Context struct {
+	// Client is the connection the command was sent on.
+	Client *Client
+	// Reply buffers the reply, in the client's protocol version.
+	Reply *protocol.Writer
+	// Keyspace is the database the client has selected.
+	Keyspace cache.Cache
+}
+
+// CommandFunc handles a registered command. args exclude the command name and
+// have already been checked against the arity. The handler writes exactly one
+// reply to ctx.Reply, unless it returns an error, which is sent as the error
+// reply instead. As with the built-in errors, the message should start with
+// an error code such as ERR.
+type CommandFunc func(ctx *Context, args []string) error
+
+// RegisterCommand adds a command to the executor's command table, leaving
+// other executors alone. arity follows Redis: it counts the command name, and
+// a negative value means at least -arity arguments. Command names are
+// case-insensitive, and existing commands cannot be replaced. Commands may be
+// registered while clients are connected.
+func (e *Executor) RegisterCommand(name string, arity int, flags CommandFlags, fn CommandFunc) error {
+	name = strings.ToLower(name)
+	switch {
+	case name == "" || strings.ContainsAny(name, " \r\n|"):
+		return fmt.Errorf("invalid command name %q", name)
+	case arity == 0:
+		return errors.New("arity must not be 0")
+	case fn == nil:
+		return errors.New("nil command handler")
+	}
+
+	e.commands.mu.Lock()
+	defer e.commands.mu.Unlock()
+
+	if _, ok := e.commands.byName[name]; ok {
+		return fmt.Errorf("command %q already exists", name)
+	}
+
+	e.commands.byName[name] = command{
+		name: name,
+		handler: func(c *Client, args []string) (string, error) {
+			ctx := &Context{Client: c, Reply: c.out, Keyspace: c.keyspace()}
+			if err := fn(ctx, args); err != nil {
+				return protocol.ErrorString(err.Error()), nil
+			}
+			return "", nil
+		},
+		arity: arity,
+		flags: flags,
+		group: "module",
+	}
+	return nil
+}
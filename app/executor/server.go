@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"log"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// ShutdownRequested is closed once a client has run SHUTDOWN. Whoever serves
+// the executor's clients is expected to stop and close them.
+func (e *Executor) ShutdownRequested() <-chan struct{} {
+	return e.shutdown
+}
+
+// handleShutdown implements SHUTDOWN [NOSAVE|SAVE] [NOW] [FORCE] [ABORT].
+// There are no replicas to wait for, so NOW changes nothing and a shutdown is
+// never pending for ABORT to cancel. Nothing is persisted either, so SAVE
+// fails unless FORCE is given. On success there is no reply: the connection
+// is closed with the others.
+func (c *Client) handleShutdown(args []string) (string, error) {
+	var save, noSave, force, abort bool
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case "save":
+			save = true
+		case "nosave":
+			noSave = true
+		case "now":
+		case "force":
+			force = true
+		case "abort":
+			abort = true
+		default:
+			return protocol.ErrorString(errSyntax.Error()), nil
+		}
+	}
+	if (save && noSave) || (abort && len(args) > 1) {
+		return protocol.ErrorString(errSyntax.Error()), nil
+	}
+	if abort {
+		return protocol.ErrorString("ERR No shutdown in progress."), nil
+	}
+
+	log.Println("User requested shutdown...")
+	if save {
+		log.Println("Error trying to save the DB: persistence is not supported")
+		if !force {
+			return protocol.ErrorString("ERR Errors trying to SHUTDOWN. Check logs."), nil
+		}
+	}
+
+	if !c.ex.shuttingDown {
+		c.ex.shuttingDown = true
+		close(c.ex.shutdown)
+	}
+	return "", nil
+}
@@ -0,0 +1,33 @@
+package executor
+
+import "strings"
+
+// slotCount is the number of hash slots keys are split into in Redis Cluster.
+const slotCount = 16384
+
+// keySlot returns the hash slot of key: CRC16 of the key, or of its hash tag
+// when it has a non-empty one between the first '{' and the next '}'.
+func keySlot(key string) uint16 {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return crc16(key) % slotCount
+}
+
+// crc16 is CRC-16/XMODEM, the variant Redis Cluster uses for key slots.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
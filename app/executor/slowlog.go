@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// Like Redis, the slow log keeps at most slowLogMaxArgs arguments of a
+// command and slowLogMaxArgLen bytes of each.
+const (
+	slowLogMaxArgs   = 32
+	slowLogMaxArgLen = 128
+
+	// DefaultSlowLogSlowerThan and DefaultSlowLogMaxLen are the defaults of
+	// slowlog-log-slower-than and slowlog-max-len.
+	DefaultSlowLogSlowerThan = 10 * time.Millisecond
+	DefaultSlowLogMaxLen     = 128
+)
+
+var slowLogHelp = []string{
+	"GET [<count>]",
+	"    Return top <count> entries from the slowlog (default: 10, -1 mean all).",
+	"    Entries are made of:",
+	"    id, timestamp, time in microseconds, arguments array, client IP and port,",
+	"    client name",
+	"LEN",
+	"    Return the length of the slowlog.",
+	"RESET",
+	"    Reset the slowlog.",
+}
+
+// slowLog is a ring of the latest commands that took longer than
+// slowlog-log-slower-than, guarded by the executor lock.
+type slowLog struct {
+	entries []slowLogEntry
+	next    int
+	// lastID is the id of the latest entry. Ids keep growing across RESET.
+	lastID int
+}
+
+type slowLogEntry struct {
+	id         int
+	at         time.Time
+	duration   time.Duration
+	args       []string
+	addr, name string
+}
+
+func (l *slowLog) add(maxLen int, entry slowLogEntry) {
+	l.lastID++
+	entry.id = l.lastID
+
+	if len(l.entries) < maxLen {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+}
+
+// latest returns up to n entries, the newest first, or all of them if n is
+// negative.
+func (l *slowLog) latest(n int) []slowLogEntry {
+	if n < 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+
+	res := make([]slowLogEntry, 0, n)
+	for i := range n {
+		// Until the ring is full, next is 0 and the newest entry the last.
+		j := (l.next - 1 - i + 2*len(l.entries)) % len(l.entries)
+		res = append(res, l.entries[j])
+	}
+	return res
+}
+
+func (l *slowLog) reset() {
+	l.entries = nil
+	l.next = 0
+}
+
+// logSlow records the request in the slow log if it took long enough.
+func (c *Client) logSlow(resp protocol.RESP, d time.Duration) {
+	threshold := c.ex.config.SlowLogSlowerThan
+	if threshold < 0 || d < threshold {
+		return
+	}
+
+	c.ex.slowLog.add(c.ex.config.SlowLogMaxLen, slowLogEntry{
+		at:       time.Now(),
+		duration: d,
+		args:     slowLogArgs(protocol.Strings(resp)),
+		addr:     c.addr,
+		name:     c.name,
+	})
+}
+
+// slowLogArgs truncates the command the way Redis does before logging it.
+func slowLogArgs(all []string) []string {
+	n := min(len(all), slowLogMaxArgs)
+	res := make([]string, 0, n)
+	for i, arg := range all[:n] {
+		if i == slowLogMaxArgs-1 && len(all) > slowLogMaxArgs {
+			res = append(res, fmt.Sprintf("... (%d more arguments)", len(all)-slowLogMaxArgs+1))
+			break
+		}
+		if len(arg) > slowLogMaxArgLen {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:slowLogMaxArgLen], len(arg)-slowLogMaxArgLen)
+		}
+		res = append(res, arg)
+	}
+	return res
+}
+
+// handleSlowLog implements SLOWLOG GET [count], SLOWLOG LEN, SLOWLOG RESET
+// and SLOWLOG HELP.
+func (c *Client) handleSlowLog(args []string) (string, error) {
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+	l := &c.ex.slowLog
+
+	wrongArgs := protocol.ErrorString("ERR wrong number of arguments for 'slowlog|" + sub + "' command")
+
+	switch sub {
+	case "get":
+		if len(args) > 1 {
+			return wrongArgs, nil
+		}
+
+		count := 10
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < -1 {
+				return protocol.ErrorString("ERR count should be greater than or equal to -1"), nil
+			}
+			count = n
+		}
+
+		res := []any{}
+		for _, e := range l.latest(count) {
+			cmd := make([]any, len(e.args))
+			for i, arg := range e.args {
+				cmd[i] = arg
+			}
+			res = append(res, []any{e.id, int(e.at.Unix()), int(e.duration.Microseconds()), cmd, e.addr, e.name})
+		}
+		return c.reply(res), nil
+	case "len":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return protocol.Integer(len(l.entries)), nil
+	case "reset":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		l.reset()
+		return protocol.SimpleString("OK"), nil
+	case "help":
+		if len(args) != 0 {
+			return wrongArgs, nil
+		}
+		return helpReply("SLOWLOG", slowLogHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try SLOWLOG HELP."), nil
+	}
+}
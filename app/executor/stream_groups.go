@@ -0,0 +1,411 @@
+package executor
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+var (
+	xClaimOptions = []string{"idle", "time", "retrycount", "force", "justid", "lastid"}
+
+	xGroupHelp = []string{
+		"CREATE <key> <groupname> <id|$> [option]",
+		"    Create a new consumer group. Options are:",
+		"    * MKSTREAM",
+		"      Create the empty stream if it does not exist.",
+		"    * ENTRIESREAD entries_read",
+		"      Set the group's entries_read counter (internal use).",
+		"CREATECONSUMER <key> <groupname> <consumer>",
+		"    Create a new consumer in the specified group.",
+		"DELCONSUMER <key> <groupname> <consumer>",
+		"    Remove the specified consumer.",
+		"DESTROY <key> <groupname>",
+		"    Remove the specified group.",
+		"SETID <key> <groupname> <id|$> [ENTRIESREAD entries_read]",
+		"    Set the current group ID and entries_read counter.",
+	}
+)
+
+func (c *Client) handleXGroup(args []string) (string, error) {
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "create":
+		if len(args) < 3 || len(args) > 6 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'xgroup|create' command"), nil
+		}
+
+		var (
+			mkStream    bool
+			entriesRead *int
+		)
+		for i := 3; i < len(args); i++ {
+			switch opt := strings.ToLower(args[i]); {
+			case opt == "mkstream":
+				mkStream = true
+			case opt == "entriesread" && i+1 < len(args):
+				n, err := parseEntriesRead(args[i+1])
+				if err != nil {
+					return protocol.ErrorString(err.Error()), nil
+				}
+				entriesRead = &n
+				i++
+			default:
+				return protocol.ErrorString(errSyntax.Error()), nil
+			}
+		}
+
+		if err := c.keyspace().XGroupCreate(args[0], args[1], args[2], mkStream, entriesRead); err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		return protocol.SimpleString("OK"), nil
+	case "setid":
+		if len(args) != 3 && len(args) != 5 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'xgroup|setid' command"), nil
+		}
+
+		var entriesRead *int
+		if len(args) == 5 {
+			if strings.ToLower(args[3]) != "entriesread" {
+				return protocol.ErrorString(errSyntax.Error()), nil
+			}
+			n, err := parseEntriesRead(args[4])
+			if err != nil {
+				return protocol.ErrorString(err.Error()), nil
+			}
+			entriesRead = &n
+		}
+
+		if err := c.keyspace().XGroupSetID(args[0], args[1], args[2], entriesRead); err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		return protocol.SimpleString("OK"), nil
+	case "destroy":
+		if len(args) != 2 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'xgroup|destroy' command"), nil
+		}
+
+		r, err := c.keyspace().XGroupDestroy(args[0], args[1])
+		if err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		return protocol.Integer(r), nil
+	case "createconsumer":
+		if len(args) != 3 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'xgroup|createconsumer' command"), nil
+		}
+
+		r, err := c.keyspace().XGroupCreateConsumer(args[0], args[1], args[2])
+		if err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		return protocol.Integer(r), nil
+	case "delconsumer":
+		if len(args) != 3 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'xgroup|delconsumer' command"), nil
+		}
+
+		r, err := c.keyspace().XGroupDelConsumer(args[0], args[1], args[2])
+		if err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		return protocol.Integer(r), nil
+	case "help":
+		if len(args) != 0 {
+			return protocol.ErrorString("ERR wrong number of arguments for 'xgroup|help' command"), nil
+		}
+		return helpReply("XGROUP", xGroupHelp...), nil
+	default:
+		return protocol.ErrorString("ERR unknown subcommand '" + sub + "'. Try XGROUP HELP."), nil
+	}
+}
+
+// parseEntriesRead parses the ENTRIESREAD argument of XGROUP CREATE and
+// SETID, where -1 stands for an unknown count.
+func parseEntriesRead(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+	if n < -1 {
+		return 0, errors.New("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return n, nil
+}
+
+func (c *Client) handleXReadGroup(w *protocol.Writer, args []string) error {
+	if len(args) < 6 || strings.ToLower(args[0]) != "group" {
+		w.WriteError("ERR wrong number of arguments for 'xreadgroup' command")
+		return nil
+	}
+
+	group, name := args[1], args[2]
+
+	var (
+		count int
+		noAck bool
+		block *time.Duration
+		i     = 3
+	)
+	for ; i < len(args); i++ {
+		opt := strings.ToLower(args[i])
+		if opt == "streams" {
+			break
+		}
+
+		switch {
+		case opt == "count" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				w.WriteError("ERR value is not an integer or out of range")
+				return nil
+			}
+			count = max(n, 0)
+			i++
+		case opt == "block" && i+1 < len(args):
+			timeout, err := parseBlockTimeout(args[i+1])
+			if err != nil {
+				w.WriteError(err.Error())
+				return nil
+			}
+			block = &timeout
+			i++
+		case opt == "noack":
+			noAck = true
+		default:
+			w.WriteError(errSyntax.Error())
+			return nil
+		}
+	}
+
+	streams := args[min(i+1, len(args)):]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		w.WriteError("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+		return nil
+	}
+
+	keys, ids := streams[:len(streams)/2], streams[len(streams)/2:]
+
+	var (
+		r   []any
+		err error
+	)
+	if block != nil && !c.ex.inExec {
+		var (
+			wait   chan []any
+			cancel func()
+		)
+		if wait, cancel, err = c.keyspace().XReadGroupBlock(group, name, keys, ids, count, noAck, *block); err == nil {
+			r = await(c, wait, cancel)
+		}
+	} else {
+		r, err = c.keyspace().XReadGroup(group, name, keys, ids, count, noAck)
+	}
+
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+	if r == nil {
+		w.WriteNullArray()
+		return nil
+	}
+
+	writeKeyed(w, r)
+	return nil
+}
+
+func (c *Client) handleXAck(args []string) (string, error) {
+	r, err := c.keyspace().XAck(args[0], args[1], args[2:])
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleXPending(w *protocol.Writer, args []string) error {
+	key, group := args[0], args[1]
+	if len(args) == 2 {
+		r, err := c.keyspace().XPending(key, group)
+		if err != nil {
+			w.WriteError(err.Error())
+			return nil
+		}
+		w.WriteValue(r)
+		return nil
+	}
+
+	rest := args[2:]
+	var minIdle time.Duration
+	if strings.ToLower(rest[0]) == "idle" {
+		if len(rest) < 2 {
+			w.WriteError(errSyntax.Error())
+			return nil
+		}
+
+		ms, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			w.WriteError("ERR value is not an integer or out of range")
+			return nil
+		}
+		minIdle = time.Duration(ms) * time.Millisecond
+		rest = rest[2:]
+	}
+
+	if len(rest) < 3 || len(rest) > 4 {
+		w.WriteError(errSyntax.Error())
+		return nil
+	}
+
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		w.WriteError("ERR value is not an integer or out of range")
+		return nil
+	}
+
+	var name string
+	if len(rest) == 4 {
+		name = rest[3]
+	}
+
+	r, err := c.keyspace().XPendingRange(key, group, minIdle, rest[0], rest[1], max(count, 0), name)
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteValue(r)
+	return nil
+}
+
+func (c *Client) handleXClaim(w *protocol.Writer, args []string) error {
+	key, group, name := args[0], args[1], args[2]
+	minIdle, err := parseMinIdle(args[3])
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	var (
+		ids  []string
+		opts cache.XClaimOptions
+		i    = 4
+	)
+	for ; i < len(args) && !slices.Contains(xClaimOptions, strings.ToLower(args[i])); i++ {
+		ids = append(ids, args[i])
+	}
+
+	for ; i < len(args); i++ {
+		opt := strings.ToLower(args[i])
+		hasValue := i+1 < len(args)
+
+		switch {
+		case opt == "force":
+			opts.Force = true
+		case opt == "justid":
+			opts.JustID = true
+		case opt == "idle" && hasValue:
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				w.WriteError("ERR Invalid IDLE option argument for XCLAIM")
+				return nil
+			}
+			idle := time.Duration(ms) * time.Millisecond
+			opts.Idle = &idle
+			i++
+		case opt == "time" && hasValue:
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				w.WriteError("ERR Invalid TIME option argument for XCLAIM")
+				return nil
+			}
+			t := time.UnixMilli(ms)
+			opts.Time = &t
+			i++
+		case opt == "retrycount" && hasValue:
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				w.WriteError("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+				return nil
+			}
+			opts.RetryCount = &n
+			i++
+		case opt == "lastid" && hasValue:
+			opts.LastID = args[i+1]
+			i++
+		default:
+			w.WriteError("ERR Unrecognized XCLAIM option '" + args[i] + "'")
+			return nil
+		}
+	}
+
+	if len(ids) == 0 {
+		w.WriteError("ERR wrong number of arguments for 'xclaim' command")
+		return nil
+	}
+
+	r, err := c.keyspace().XClaim(key, group, name, minIdle, ids, opts)
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteValue(r)
+	return nil
+}
+
+func (c *Client) handleXAutoClaim(w *protocol.Writer, args []string) error {
+	key, group, name, start := args[0], args[1], args[2], args[4]
+	minIdle, err := parseMinIdle(args[3])
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	var (
+		count  = 100
+		justID bool
+	)
+	for i := 5; i < len(args); i++ {
+		opt := strings.ToLower(args[i])
+		switch {
+		case opt == "count" && i+1 < len(args):
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				w.WriteError("ERR COUNT must be > 0")
+				return nil
+			}
+			i++
+		case opt == "justid":
+			justID = true
+		default:
+			w.WriteError(errSyntax.Error())
+			return nil
+		}
+	}
+
+	r, err := c.keyspace().XAutoClaim(key, group, name, minIdle, start, count, justID)
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteValue(r)
+	return nil
+}
+
+func parseMinIdle(arg string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errors.New("ERR Invalid min-idle-time argument")
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond, nil
+}
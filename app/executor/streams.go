@@ -1,76 +1,346 @@
 package executor
 
 import (
-	"slices"
+	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/cache"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 )
 
-func handleXAdd(args []string) (string, error) {
-	if len(args) < 2 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'xadd' command"), nil
-	}
-
+func (c *Client) handleXAdd(args []string) (string, error) {
 	key := args[0]
-	id := args[1]
+	args = args[1:]
+
+	var (
+		noMkStream bool
+		trim       *cache.XTrimOptions
+	)
+	for len(args) > 0 {
+		opt := strings.ToLower(args[0])
+		if opt == "nomkstream" {
+			noMkStream = true
+			args = args[1:]
+			continue
+		}
 
-	if id == "0-0" {
-		return protocol.ErrorString("ERR The ID specified in XADD must be greater than 0-0"), nil
+		if opt != cache.TrimMaxLen && opt != cache.TrimMinID {
+			break
+		}
+
+		opts, n, err := parseXTrimOptions(args)
+		if err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
+		trim = &opts
+		args = args[n:]
+	}
+
+	if len(args) == 0 {
+		return protocol.ErrorString("ERR wrong number of arguments for 'xadd' command"), nil
 	}
 
-	if len(args) < 3 || len(args)%2 != 0 {
+	id := args[0]
+	if len(args) < 3 || len(args)%2 != 1 {
 		return protocol.ErrorString("ERR wrong number of arguments for 'xadd' command"), nil
 	}
 
-	otherArgs := make([]any, len(args[2:]))
+	if noMkStream && c.keyspace().Type(key) == "none" {
+		return c.reply(nil), nil
+	}
+
+	otherArgs := make([]any, len(args[1:]))
 	for i := 0; i < len(otherArgs); i++ {
-		otherArgs[i] = args[i+2]
+		otherArgs[i] = args[i+1]
 	}
 
-	r, ok := cache.XAdd(key, id, otherArgs)
-	if !ok {
-		return protocol.ErrorString("ERR The ID specified in XADD is equal or smaller than the target stream top item"), nil
+	r, err := c.keyspace().XAdd(key, id, otherArgs)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	if trim != nil {
+		if _, err := c.keyspace().XTrim(key, *trim); err != nil {
+			return protocol.ErrorString(err.Error()), nil
+		}
 	}
 
 	return protocol.BulkString(r), nil
 }
 
-func handleXRange(args []string) (string, error) {
-	if len(args) < 3 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'xrange' command"), nil
+func (c *Client) handleXRange(w *protocol.Writer, args []string) error {
+	return c.xRange(w, "xrange", args, false)
+}
+
+func (c *Client) handleXRevRange(w *protocol.Writer, args []string) error {
+	return c.xRange(w, "xrevrange", args, true)
+}
+
+func (c *Client) xRange(w *protocol.Writer, name string, args []string, rev bool) error {
+	if len(args) != 3 && len(args) != 5 {
+		w.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return nil
+	}
+
+	var count int
+	if len(args) == 5 {
+		if strings.ToLower(args[3]) != "count" {
+			w.WriteError("ERR syntax error")
+			return nil
+		}
+
+		n, err := strconv.Atoi(args[4])
+		if err != nil {
+			w.WriteError("ERR value is not an integer or out of range")
+			return nil
+		}
+		if n <= 0 {
+			w.WriteArrayHeader(0)
+			return nil
+		}
+		count = n
+	}
+
+	first := strings.TrimSpace(args[1])
+	second := strings.TrimSpace(args[2])
+
+	var (
+		r   []any
+		err error
+	)
+	if rev {
+		r, err = c.keyspace().XRevRange(args[0], first, second, count)
+	} else {
+		r, err = c.keyspace().XRange(args[0], first, second, count)
+	}
+	if err != nil {
+		w.WriteError(err.Error())
+		return nil
+	}
+
+	w.WriteArrayHeader(len(r))
+	for _, e := range r {
+		w.WriteValue(e)
+	}
+	return nil
+}
+
+func (c *Client) handleXLen(args []string) (string, error) {
+	return protocol.Integer(c.keyspace().XLen(args[0])), nil
+}
+
+func (c *Client) handleXDel(args []string) (string, error) {
+	r, err := c.keyspace().XDel(args[0], args[1:])
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+func (c *Client) handleXTrim(args []string) (string, error) {
+	opts, n, err := parseXTrimOptions(args[1:])
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+	if n != len(args)-1 {
+		return protocol.ErrorString("ERR syntax error"), nil
+	}
+
+	r, err := c.keyspace().XTrim(args[0], opts)
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+
+	return protocol.Integer(r), nil
+}
+
+var xInfoHelp = []string{
+	"CONSUMERS <key> <groupname>",
+	"    Show consumers of <groupname>.",
+	"GROUPS <key>",
+	"    Show the stream consumer groups.",
+	"STREAM <key>",
+	"    Show information about the stream.",
+}
+
+func (c *Client) handleXInfo(args []string) (string, error) {
+	if len(args) == 1 && strings.ToLower(args[0]) == "help" {
+		return helpReply("XINFO", xInfoHelp...), nil
+	}
+	if len(args) < 2 {
+		return protocol.ErrorString("ERR wrong number of arguments for 'xinfo' command"), nil
+	}
+
+	var (
+		r   []any
+		err error
+	)
+	switch sub := strings.ToLower(args[0]); {
+	case sub == "stream" && len(args) == 2:
+		r, err = c.keyspace().XInfoStream(args[1])
+	case sub == "groups" && len(args) == 2:
+		r, err = c.keyspace().XInfoGroups(args[1])
+	case sub == "consumers" && len(args) == 3:
+		r, err = c.keyspace().XInfoConsumers(args[1], args[2])
+	default:
+		return protocol.ErrorString("ERR unknown subcommand or wrong number of arguments for '" + args[0] + "'. Try XINFO HELP."), nil
+	}
+
+	if err != nil {
+		return protocol.ErrorString(err.Error()), nil
+	}
+	if strings.ToLower(args[0]) == "stream" {
+		return c.reply(protocol.Map(r)), nil
+	}
+
+	// GROUPS and CONSUMERS reply with one map per group or consumer.
+	for i, v := range r {
+		r[i] = protocol.Map(v.([]any))
+	}
+	return c.reply(r), nil
+}
+
+// parseXTrimOptions parses a MAXLEN|MINID [=|~] threshold [LIMIT count]
+// clause at the start of args and returns how many arguments it used.
+func parseXTrimOptions(args []string) (cache.XTrimOptions, int, error) {
+	var opts cache.XTrimOptions
+	if len(args) < 2 {
+		return opts, 0, errSyntax
+	}
+
+	opts.Strategy = strings.ToLower(args[0])
+	if opts.Strategy != cache.TrimMaxLen && opts.Strategy != cache.TrimMinID {
+		return opts, 0, errSyntax
+	}
+
+	i := 1
+	switch args[i] {
+	case "~":
+		opts.Approx = true
+		i++
+	case "=":
+		i++
+	}
+	if i >= len(args) {
+		return opts, 0, errSyntax
+	}
+
+	if opts.Strategy == cache.TrimMaxLen {
+		n, err := strconv.Atoi(args[i])
+		if err != nil {
+			return opts, 0, errors.New("ERR value is not an integer or out of range")
+		}
+		if n < 0 {
+			return opts, 0, errors.New("ERR The MAXLEN argument must be >= 0.")
+		}
+		opts.MaxLen = n
+	} else {
+		opts.MinID = args[i]
 	}
+	i++
+
+	if i < len(args) && strings.ToLower(args[i]) == "limit" {
+		if !opts.Approx {
+			return opts, 0, errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		if i+1 >= len(args) {
+			return opts, 0, errSyntax
+		}
 
-	start := strings.TrimSpace(args[1])
-	end := strings.TrimSpace(args[2])
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n < 0 {
+			return opts, 0, errors.New("ERR The LIMIT argument must be >= 0.")
+		}
+		opts.Limit = n
+		if n == 0 {
+			opts.Limit = -1
+		}
+		i += 2
+	}
 
-	r := cache.XRange(args[0], start, end)
-	return protocol.Array(r), nil
+	return opts, i, nil
 }
 
-func handleXRead(args []string) (string, error) {
-	if len(args) < 3 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'xread' command"), nil
+func (c *Client) handleXRead(w *protocol.Writer, args []string) error {
+	var (
+		count int
+		block *time.Duration
+		i     int
+	)
+	for ; i < len(args); i++ {
+		opt := strings.ToLower(args[i])
+		if opt == "streams" {
+			break
+		}
+
+		switch {
+		case opt == "count" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				w.WriteError("ERR value is not an integer or out of range")
+				return nil
+			}
+			count = max(n, 0)
+			i++
+		case opt == "block" && i+1 < len(args):
+			timeout, err := parseBlockTimeout(args[i+1])
+			if err != nil {
+				w.WriteError(err.Error())
+				return nil
+			}
+			block = &timeout
+			i++
+		default:
+			w.WriteError("ERR syntax error")
+			return nil
+		}
 	}
 
-	allowedOptions := []string{"streams", "block"}
-	if slices.Contains(allowedOptions, strings.ToLower(args[0])) {
-		return protocol.ErrorString("ERR wrong argument for 'xread' command"), nil
+	streams := args[min(i+1, len(args)):]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		w.WriteError("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+		return nil
 	}
 
-	lenArgs := len(args) - 1
-	if lenArgs%2 != 0 {
-		return protocol.ErrorString("ERR wrong number of arguments for 'xread' command"), nil
+	keys, ids := streams[:len(streams)/2], streams[len(streams)/2:]
+	for _, id := range ids {
+		if id == "$" {
+			continue
+		}
+		if _, err := cache.ParseStreamID(id); err != nil {
+			w.WriteError(err.Error())
+			return nil
+		}
 	}
 
-	keys := make([]string, lenArgs/2)
-	ids := make([]string, lenArgs/2)
-	for i := 0; i < lenArgs/2; i++ {
-		keys[i] = args[i+1]
-		ids[i] = args[i+1+lenArgs/2]
+	var r []any
+	if block != nil && !c.ex.inExec {
+		wait, cancel := c.keyspace().XReadBlock(keys, ids, count, *block)
+		r = await(c, wait, cancel)
+	} else {
+		r = c.keyspace().XRead(keys, ids, count)
 	}
 
-	r := cache.XRead(args[0], keys, ids)
-	return protocol.Array(r), nil
+	if r == nil {
+		w.WriteNullArray()
+		return nil
+	}
+	writeKeyed(w, r)
+	return nil
+}
+
+// parseBlockTimeout parses the millisecond BLOCK argument of XREAD and
+// XREADGROUP, where 0 means wait forever.
+func parseBlockTimeout(arg string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errors.New("ERR timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
 }
@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// transaction is the MULTI state of a client. aborted is set when a command
+// could not be queued, which makes EXEC fail as a whole. watched maps each
+// WATCHed key to its version at the time of the WATCH, and dirty is set when
+// a watched database was swapped out from under the client.
+type transaction struct {
+	active  bool
+	aborted bool
+	dirty   bool
+	queued  [][]string
+	watched map[watchedKey]uint64
+}
+
+// watchedKey is a key in the database it was watched in, which SWAPDB may
+// move to another index.
+type watchedKey struct {
+	db  cache.Cache
+	key string
+}
+
+func (c *Client) handleMulti(args []string) (string, error) {
+	if c.tx.active {
+		return protocol.ErrorString("ERR MULTI calls can not be nested"), nil
+	}
+
+	c.tx.active = true
+	return protocol.SimpleString("OK"), nil
+}
+
+// queue stores a command issued after MULTI for EXEC. The command has been
+// looked up already, so only runtime errors are left for EXEC to report.
+func (c *Client) queue(name string, args []string) string {
+	c.tx.queued = append(c.tx.queued, append([]string{name}, args...))
+	return protocol.SimpleString("QUEUED")
+}
+
+// handleExec runs the queued commands back to back under the executor lock, so
+// no other client observes or changes the keyspace in between. It replies
+// with a null array when a watched key was modified since WATCH. The replies
+// of the queued commands are written straight to the client's buffer after
+// the array header.
+func (c *Client) handleExec(args []string) (string, error) {
+	if !c.tx.active {
+		return protocol.ErrorString("ERR EXEC without MULTI"), nil
+	}
+
+	queued, aborted, dirty := c.tx.queued, c.tx.aborted, c.watchedKeyChanged()
+	c.discard()
+
+	switch {
+	case aborted:
+		return protocol.ErrorString("EXECABORT Transaction discarded because of previous errors."), nil
+	case dirty:
+		return c.nullArrayReply(), nil
+	}
+
+	c.ex.inExec = true
+	defer func() { c.ex.inExec = false }()
+
+	c.out.WriteArrayHeader(len(queued))
+	for _, q := range queued {
+		cmd, _ := c.ex.commands.get(q[0])
+		if err := cmd.call(c, q[1:]); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func (c *Client) handleDiscard(args []string) (string, error) {
+	if !c.tx.active {
+		return protocol.ErrorString("ERR DISCARD without MULTI"), nil
+	}
+
+	c.discard()
+	return protocol.SimpleString("OK"), nil
+}
+
+// discard leaves MULTI and, as EXEC and DISCARD always do, unwatches all keys.
+func (c *Client) discard() {
+	c.tx.active, c.tx.aborted, c.tx.queued = false, false, nil
+	c.unwatchAll()
+}
+
+func (c *Client) handleWatch(args []string) (string, error) {
+	if c.tx.active {
+		return protocol.ErrorString("ERR WATCH inside MULTI is not allowed"), nil
+	}
+
+	if c.tx.watched == nil {
+		c.tx.watched = make(map[watchedKey]uint64)
+	}
+	db := c.keyspace()
+	for _, key := range args {
+		if _, ok := c.tx.watched[watchedKey{db, key}]; !ok {
+			c.tx.watched[watchedKey{db, key}] = db.Watch(key)
+		}
+	}
+	return protocol.SimpleString("OK"), nil
+}
+
+func (c *Client) handleUnwatch(args []string) (string, error) {
+	c.unwatchAll()
+	return protocol.SimpleString("OK"), nil
+}
+
+func (c *Client) unwatchAll() {
+	for w := range c.tx.watched {
+		w.db.Unwatch(w.key)
+	}
+	c.tx.watched, c.tx.dirty = nil, false
+}
+
+func (c *Client) watchedKeyChanged() bool {
+	if c.tx.dirty {
+		return true
+	}
+	for w, version := range c.tx.watched {
+		if w.db.KeyVersion(w.key) != version {
+			return true
+		}
+	}
+	return false
+}
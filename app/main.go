@@ -1,98 +1,121 @@
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/executor"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
 )
 
-// Ensures gofmt doesn't remove the "net" and "os" imports in stage 1 (feel free to remove this!)
-var (
-	_ = net.Listen
-	_ = os.Exit
-)
+// shutdownTimeout bounds how long a graceful shutdown waits for clients.
+const shutdownTimeout = 10 * time.Second
 
 func main() {
 	// You can use print statements as follows for debugging, they'll be visible when running tests.
 	fmt.Println("Logs from your program will appear here!")
 
-	// Uncomment this block to pass the first stage
-	//
-	l, err := net.Listen("tcp", "0.0.0.0:6379")
-	if err != nil {
-		fmt.Println("Failed to bind to port 6379")
-		os.Exit(1)
-	}
-
-	defer func() {
-		if err := l.Close(); err != nil {
-			log.Fatal(err)
+	config := server.Config{Addr: server.DefaultAddr, Limits: protocol.DefaultLimits, Databases: server.DefaultDatabases}
+	flag.IntVar(&config.Databases, "databases", config.Databases, "number of databases")
+	flag.IntVar(&config.Limits.MaxBulkLen, "proto-max-bulk-len", config.Limits.MaxBulkLen, "longest bulk string accepted in a request, in bytes")
+	flag.IntVar(&config.Limits.MaxMultiBulkLen, "max-multibulk-len", config.Limits.MaxMultiBulkLen, "largest number of arguments accepted in a request")
+	flag.IntVar(&config.Limits.MaxQueryBuffer, "client-query-buffer-limit", config.Limits.MaxQueryBuffer, "largest request accepted, in bytes")
+	flag.Func("maxmemory", "memory the data may use before keys are evicted, such as 100mb; 0 for no limit", func(s string) (err error) {
+		config.MaxMemory, err = parseMemory(s)
+		return err
+	})
+	flag.Func("maxmemory-policy", "how keys are picked for eviction: noeviction, allkeys-lru, allkeys-lfu, allkeys-random, volatile-lru, volatile-lfu, volatile-random or volatile-ttl", func(s string) (err error) {
+		config.MaxMemoryPolicy, err = executor.ParseEvictionPolicy(s)
+		return err
+	})
+	flag.IntVar(&config.MaxMemorySamples, "maxmemory-samples", 5, "number of keys sampled per database to pick one to evict")
+	flag.Func("maxmemory-clients", "memory the replies waiting to be read by clients may use before clients are evicted, such as 100mb; 0 for no limit", func(s string) (err error) {
+		config.MaxMemoryClients, err = parseMemory(s)
+		return err
+	})
+	flag.Func("slowlog-log-slower-than", "microseconds a command must run to be logged in the slow log; 0 logs every command, a negative value none (default 10000)", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
 		}
-	}()
 
-	connChan := readConnection(l)
+		switch {
+		case n < 0:
+			config.SlowLogSlowerThan = -1
+		case n == 0:
+			// A zero duration means the default, and every command takes
+			// at least a nanosecond.
+			config.SlowLogSlowerThan = time.Nanosecond
+		default:
+			config.SlowLogSlowerThan = time.Duration(n) * time.Microsecond
+		}
+		return nil
+	})
+	flag.IntVar(&config.SlowLogMaxLen, "slowlog-max-len", executor.DefaultSlowLogMaxLen, "number of commands the slow log keeps")
+	flag.Func("latency-monitor-threshold", "milliseconds from which the latency monitor records events; 0 turns it off", func(s string) error {
+		n, err := strconv.Atoi(s)
+		config.LatencyMonitorThreshold = time.Duration(n) * time.Millisecond
+		return err
+	})
+	flag.Parse()
+
+	// SIGINT and SIGTERM shut the server down gracefully, like SHUTDOWN does.
+	// A second signal kills it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(config)
+	if err := srv.Start(ctx); err != nil {
+		fmt.Printf("Failed to bind to %s: %v\n", config.Addr, err)
+		os.Exit(1)
+	}
 
-	parseCommand(connChan)
+	<-srv.Done()
+	stop()
 
+	log.Println("Shutting down, waiting for clients to finish their commands")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown: %v", err)
+	}
+	log.Println("Redis is now ready to exit, bye bye...")
 }
 
-func readConnection(l net.Listener) chan net.Conn {
-	connChan := make(chan net.Conn)
-
-	go func() {
-		for {
-			conn, err := l.Accept()
-			if err != nil {
-				fmt.Println("Error accepting connection: ", err.Error())
-				os.Exit(1)
-			}
-
-			connChan <- conn
-		}
-	}()
-	return connChan
+// memoryUnits are the suffixes Redis accepts in memory settings. The ones
+// ending in b are powers of 1024.
+var memoryUnits = []struct {
+	suffix string
+	bytes  int
+}{
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
 }
 
-func parseCommand(connChan chan net.Conn) {
-	for {
-		select {
-		case conn := <-connChan:
-			buff := bufio.NewReader(conn)
-			go func(conn net.Conn) {
-				defer closeConnection(conn)
-				for {
-					res, err := protocol.ParseRequest(buff)
-					if err != nil {
-						if errors.Is(err, io.EOF) {
-							continue
-						}
-
-						log.Println(err)
-					}
-
-					resp, err := executor.Execute(res)
-					if err != nil {
-						log.Println(err)
-					}
-
-					if _, err = conn.Write([]byte(resp)); err != nil {
-						log.Println(err)
-					}
-				}
-			}(conn)
+// parseMemory parses an amount of memory the way redis.conf does.
+func parseMemory(s string) (int, error) {
+	s = strings.ToLower(s)
+	unit := 1
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSuffix(s, u.suffix), u.bytes
+			break
 		}
 	}
-}
 
-func closeConnection(conn net.Conn) {
-	if err := conn.Close(); err != nil {
-		log.Fatal(err)
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory amount %q", s)
 	}
+	return n * unit, nil
 }
@@ -2,11 +2,13 @@ package protocol
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/big"
 	"strconv"
-	"strings"
 )
 
 const (
@@ -44,237 +46,680 @@ var (
 	_ RESP = (*simpleStringRESP)(nil)
 	_ RESP = (*errorStringRESP)(nil)
 	_ RESP = (*integerRESP)(nil)
+	_ RESP = (*nullRESP)(nil)
+	_ RESP = (*booleanRESP)(nil)
+	_ RESP = (*doubleRESP)(nil)
+	_ RESP = (*bigNumberRESP)(nil)
+	_ RESP = (*bulkErrorRESP)(nil)
+	_ RESP = (*verbatimRESP)(nil)
+	_ RESP = (*mapRESP)(nil)
+	_ RESP = (*attributedRESP)(nil)
+	_ RESP = (*setRESP)(nil)
+	_ RESP = (*pushRESP)(nil)
 )
 
+// Protocol versions a connection can speak, as negotiated with HELLO.
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// ErrProtocol is wrapped by the errors of requests that don't follow the
+// protocol or exceed the Limits. The connection cannot be read any further
+// after one.
+var ErrProtocol = errors.New("Protocol error")
+
+// Limits protect the server from requests that would make it allocate too
+// much memory. They are checked before anything is allocated for a request.
+type Limits struct {
+	// MaxBulkLen is the longest bulk string accepted, Redis'
+	// proto-max-bulk-len.
+	MaxBulkLen int
+	// MaxMultiBulkLen is the largest number of elements in an aggregate.
+	MaxMultiBulkLen int
+	// MaxQueryBuffer is the largest size of a single request, Redis'
+	// client-query-buffer-limit.
+	MaxQueryBuffer int
+}
+
+// DefaultLimits are the limits Redis uses by default.
+var DefaultLimits = Limits{
+	MaxBulkLen:      512 << 20,
+	MaxMultiBulkLen: 1 << 20,
+	MaxQueryBuffer:  1 << 30,
+}
+
+// maxInlineSize bounds inline requests and the header lines of every type.
+const maxInlineSize = 64 << 10
+
+// maxNesting bounds how deeply aggregates may nest in a value, so that a peer
+// cannot exhaust the stack.
+const maxNesting = 128
+
+// maxPrealloc bounds what is allocated up front from a length header, so that
+// a client cannot make the server allocate memory it never sends. Larger
+// values grow as they arrive.
+const maxPrealloc = 64 << 10
+
+func protocolError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrProtocol, msg)
+}
+
+// ParseRequest reads one request with the DefaultLimits.
 func ParseRequest(buff *bufio.Reader) (RESP, error) {
-	prefix, err := buff.ReadByte()
+	return ParseRequestWithLimits(buff, DefaultLimits)
+}
+
+// ParseRequestWithLimits reads one request, rejecting it with an ErrProtocol
+// error as soon as it is known to exceed limits. A request is either an array
+// of bulk strings or an inline command: no other type is accepted.
+func ParseRequestWithLimits(buff *bufio.Reader, limits Limits) (RESP, error) {
+	p := parser{buff: buff, limits: limits}
+	return p.parseRequest()
+}
+
+// ParseValue reads a single value of any RESP2 or RESP3 type, such as a
+// reply, with the DefaultLimits.
+func ParseValue(buff *bufio.Reader) (RESP, error) {
+	p := parser{buff: buff, limits: DefaultLimits}
+	return p.parse()
+}
+
+// parser reads a single value. size counts the bytes read so far, for the
+// query buffer limit, and depth the aggregates being read.
+type parser struct {
+	buff   *bufio.Reader
+	limits Limits
+	size   int
+	depth  int
+}
+
+// parseRequest reads a request. Empty and null arrays are skipped, as Redis
+// does.
+func (p *parser) parseRequest() (RESP, error) {
+	for {
+		prefix, err := p.buff.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		p.size++
+
+		switch prefix {
+		case array:
+			n, err := p.readMultiBulkLength()
+			if err != nil {
+				return nil, err
+			}
+			if n <= 0 {
+				continue
+			}
+
+			args, err := p.readArgs(n)
+			if err != nil {
+				return nil, err
+			}
+			return arrayRESP(args), nil
+		case simpleString, errorString, integer, bulkString, nulls, booleans, doubles,
+			bigNumbers, bulkErrors, verbatim, maps, attributes, sets, pushes:
+			return nil, protocolError(fmt.Sprintf("expected '$', got '%c'", prefix))
+		default:
+			// Anything else is an inline command.
+			if err := p.buff.UnreadByte(); err != nil {
+				return nil, err
+			}
+			p.size--
+			return p.parseInline()
+		}
+	}
+}
+
+func (p *parser) parse() (RESP, error) {
+	if p.depth == maxNesting {
+		return nil, protocolError("too deeply nested value")
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	prefix, err := p.buff.ReadByte()
 	if err != nil {
 		return nil, err
 	}
+	p.size++
 
 	switch prefix {
 	case simpleString:
-		arg, err := buff.ReadString(newline)
+		arg, err := p.readLine()
 		if err != nil {
 			return nil, err
 		}
-
-		arg = strings.TrimSpace(arg)
 		return simpleStringRESP(arg), nil
 	case errorString:
-		arg, err := buff.ReadString(newline)
+		arg, err := p.readLine()
 		if err != nil {
 			return nil, err
 		}
-
-		arg = strings.TrimSpace(arg)
 		return errorStringRESP(arg), nil
 	case integer:
-		arg, err := buff.ReadString(newline)
+		arg, err := p.readLine()
 		if err != nil {
 			return nil, err
 		}
 
-		arg = strings.TrimSpace(arg)
 		n, err := strconv.Atoi(arg)
 		if err != nil {
-			return nil, errors.Join(errors.New(" failed to parse integer"), err)
+			return nil, protocolError("invalid integer")
 		}
-
 		return integerRESP(n), nil
 	case bulkString:
-		nStr, err := buff.ReadString(newline)
+		n, err := p.readBulkLength()
+		if err != nil {
+			return nil, err
+		}
+		if n == -1 {
+			return nullRESP{}, nil
+		}
+
+		str, err := p.readBlob(n)
+		if err != nil {
+			return nil, err
+		}
+		return bulkStringRESP(str), nil
+	case array:
+		n, err := p.readMultiBulkLength()
 		if err != nil {
 			return nil, err
 		}
+		if n == -1 {
+			return nullRESP{}, nil
+		}
 
-		nStr = strings.TrimSpace(nStr)
-		n, err := strconv.Atoi(nStr)
+		elems, err := p.readElems(n)
+		if err != nil {
+			return nil, err
+		}
+		return arrayRESP(elems), nil
+	case nulls:
+		arg, err := p.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if arg != "" {
+			return nil, protocolError("invalid null")
+		}
+		return nullRESP{}, nil
+	case booleans:
+		arg, err := p.readLine()
 		if err != nil {
-			return nil, errors.Join(errors.New(" failed to parse bulk string size"), err)
+			return nil, err
 		}
-		_ = n
 
-		str, err := buff.ReadString(newline)
+		switch arg {
+		case "t":
+			return booleanRESP(true), nil
+		case "f":
+			return booleanRESP(false), nil
+		default:
+			return nil, protocolError("invalid boolean")
+		}
+	case doubles:
+		arg, err := p.readLine()
 		if err != nil {
 			return nil, err
 		}
-		str = strings.TrimSpace(str)
 
-		return bulkStringRESP(str), nil
-	case array:
-		nStr, err := buff.ReadString(newline)
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, protocolError("invalid double")
+		}
+		return doubleRESP(f), nil
+	case bigNumbers:
+		arg, err := p.readLine()
 		if err != nil {
 			return nil, err
 		}
 
-		nStr = strings.TrimSpace(nStr)
-		n, err := strconv.Atoi(nStr)
+		n, ok := new(big.Int).SetString(arg, 10)
+		if !ok {
+			return nil, protocolError("invalid big number")
+		}
+		return (*bigNumberRESP)(n), nil
+	case bulkErrors:
+		n, err := p.readBulkLength()
 		if err != nil {
-			return nil, errors.Join(errors.New(" failed to parse bulk string size"), err)
+			return nil, err
 		}
 
-		if n == -1 {
-			return nil, nil
+		str, err := p.readBlob(n)
+		if err != nil {
+			return nil, err
+		}
+		return bulkErrorRESP(str), nil
+	case verbatim:
+		n, err := p.readBulkLength()
+		if err != nil {
+			return nil, err
 		}
 
-		args := make(arrayRESP, n)
-		for i := 0; i < n; i++ {
-			args[i], err = ParseRequest(buff)
-			if err != nil {
-				return nil, err
-			}
+		str, err := p.readBlob(n)
+		if err != nil {
+			return nil, err
+		}
+		if len(str) < 4 || str[3] != ':' {
+			return nil, protocolError("invalid verbatim string")
+		}
+		return verbatimRESP{format: str[:3], text: str[4:]}, nil
+	case maps, attributes:
+		n, err := p.readMultiBulkLength()
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n > p.limits.MaxMultiBulkLen/2 {
+			return nil, protocolError("invalid multibulk length")
+		}
+
+		elems, err := p.readElems(2 * n)
+		if err != nil {
+			return nil, err
+		}
+		m := make(mapRESP, len(elems)/2)
+		for i := range m {
+			m[i] = [2]RESP{elems[2*i], elems[2*i+1]}
 		}
-		return args, nil
-	//case nulls:
-	//case booleans:
-	//case doubles:
-	//case bigNumbers:
-	//case bulkErrors:
-	//case verbatim:
-	//case maps:
-	//case attributes:
-	//case sets:
-	//case pushes:
+		if prefix == maps {
+			return m, nil
+		}
+
+		// Attributes annotate the value that follows them.
+		val, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		return attributedRESP{RESP: val, attributes: m}, nil
+	case sets, pushes:
+		n, err := p.readMultiBulkLength()
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, protocolError("invalid multibulk length")
+		}
+
+		elems, err := p.readElems(n)
+		if err != nil {
+			return nil, err
+		}
+		if prefix == sets {
+			return setRESP(elems), nil
+		}
+		return pushRESP(elems), nil
 	default:
-		return errorStringRESP("ERR not implemented"), nil
+		return nil, protocolError(fmt.Sprintf("unknown type '%c'", prefix))
+	}
+}
 
+// readLine reads up to the next CRLF and returns what precedes it. Lines are
+// read in chunks, so an overlong one is rejected before it is buffered whole.
+func (p *parser) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := p.buff.ReadSlice(newline)
+		if len(line)+len(chunk) > maxInlineSize {
+			return "", protocolError("too big inline request")
+		}
+		if err := p.grow(len(chunk)); err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == returnLine {
+		line = line[:len(line)-1]
 	}
+	return string(line), nil
 }
 
-func SimpleString(s string) string {
-	return fmt.Sprintf("%c%s\r\n", simpleString, s)
+// readBulkLength reads the length of a bulk string, which is -1 for a null.
+func (p *parser) readBulkLength() (int, error) {
+	return p.readLength(p.limits.MaxBulkLen, "invalid bulk length")
 }
 
-func ErrorString(s string) string {
-	return fmt.Sprintf("%c%s\r\n", errorString, s)
+// readMultiBulkLength reads the number of elements of an aggregate, which is
+// -1 for a null array.
+func (p *parser) readMultiBulkLength() (int, error) {
+	return p.readLength(p.limits.MaxMultiBulkLen, "invalid multibulk length")
 }
 
-func Integer(i int) string {
-	return fmt.Sprintf("%c%d\r\n", integer, i)
+// readLength reads a length between -1 and max, failing with the protocol
+// error invalid otherwise.
+func (p *parser) readLength(max int, invalid string) (int, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(line)
+	if err != nil || n < -1 || n > max {
+		return 0, protocolError(invalid)
+	}
+	return n, nil
 }
 
-func BulkString(s string) string {
-	l := len(s)
-	if l == 0 {
-		l = -1
-		return fmt.Sprintf("%c%d\r\n", bulkString, l)
+// readBlob reads a length-prefixed payload and the CRLF ending it. The
+// payload may contain CR and LF itself.
+func (p *parser) readBlob(n int) (string, error) {
+	if n < 0 {
+		return "", protocolError("invalid bulk length")
+	}
+	if err := p.grow(n + 2); err != nil {
+		return "", err
 	}
 
-	resp := fmt.Sprintf("%c%d\r\n%s\r\n", bulkString, l, s)
-	return resp
+	var blob bytes.Buffer
+	blob.Grow(min(n+2, maxPrealloc))
+	if _, err := io.CopyN(&blob, p.buff, int64(n+2)); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if string(blob.Bytes()[n:]) != crlf {
+		return "", protocolError("payload not terminated by CRLF")
+	}
+	return string(blob.Bytes()[:n]), nil
 }
 
-func Array(a []any) string {
-	i := len(a)
-
-	resp := fmt.Sprintf("%c%d\r\n", array, i)
-	for _, v := range a { // TODO: add more types
-		switch v.(type) {
-		case string:
-			resp += BulkString(v.(string))
-		case int:
-			resp += Integer(v.(int))
-		case bool:
-			resp += Booleans(v.(bool))
-		case error:
-			resp += ErrorString(v.(error).Error())
-		case []any:
-			resp += Array(v.([]any))
-		case [2]any:
-			newArgs := make([]any, 2)
-			newArgs[0] = v.([2]any)[0]
-			newArgs[1] = v.([2]any)[1]
-			resp += Array(newArgs)
-		default:
-			resp += Nulls()
+func (p *parser) readElems(n int) ([]RESP, error) {
+	elems := make([]RESP, 0, min(n, maxPrealloc))
+	for range n {
+		elem, err := p.parse()
+		if err != nil {
+			return nil, err
 		}
+		elems = append(elems, elem)
 	}
-	return resp
+	return elems, nil
 }
 
-func Nulls() string {
-	return fmt.Sprintf("%c\r\n", nulls)
+// readArgs reads the n arguments of a request, which must all be bulk
+// strings.
+func (p *parser) readArgs(n int) ([]RESP, error) {
+	args := make([]RESP, 0, min(n, maxPrealloc))
+	for range n {
+		prefix, err := p.buff.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		p.size++
+		if prefix != bulkString {
+			return nil, protocolError(fmt.Sprintf("expected '$', got '%c'", prefix))
+		}
+
+		n, err := p.readBulkLength()
+		if err != nil {
+			return nil, err
+		}
+		str, err := p.readBlob(n)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, bulkStringRESP(str))
+	}
+	return args, nil
 }
 
-func Booleans(val bool) string {
-	str := "f"
-	if val {
-		str = "t"
+// grow accounts for n more bytes of the request, failing once the request
+// would exceed the query buffer limit.
+func (p *parser) grow(n int) error {
+	p.size += n
+	if p.size > p.limits.MaxQueryBuffer {
+		return protocolError("query buffer limit exceeded")
 	}
-	return fmt.Sprintf("%c%s\r\n", booleans, str)
+	return nil
 }
 
-func Doubles(val float64) string {
-	return fmt.Sprintf("%c%f\r\n", doubles, val)
+func SimpleString(s string) string {
+	return string(appendLine(nil, simpleString, s))
 }
 
-func BigNumbers(val big.Int) string {
-	return fmt.Sprintf("%c%s\r\n", bigNumbers, val.String())
+func ErrorString(s string) string {
+	return string(appendLine(nil, errorString, s))
 }
 
-func BulkErrors(val []error) string {
-	resp := fmt.Sprintf("%c%d\r\n", bulkErrors, len(val))
-	for _, v := range val {
-		resp += ErrorString(v.Error())
-	}
+func Integer(i int) string {
+	return string(appendHeader(nil, integer, i))
+}
 
-	return resp
+// BulkString encodes s as a bulk string; Encode(nil, version) is the null
+// reply.
+func BulkString(s string) string {
+	return string(appendBulk(nil, s))
 }
 
-func Verbatim(val, encoding string) string {
-	defaultEncoding := "txt"
-	if encoding == "" {
-		encoding = defaultEncoding
-	}
+// Array encodes a RESP2 array.
+func Array(a []any) string {
+	return string(appendAggregate(nil, array, a, RESP2))
+}
 
-	resp := fmt.Sprintf("%c%d\r\n", verbatim, len(val))
-	return resp + BulkString(encoding+":"+val)
+// Map is an ordered map reply of alternating keys and values. It is sent as
+// a map in RESP3 and as a flat array in RESP2.
+type Map []any
+
+// Set is a set reply, sent as an array in RESP2.
+type Set []any
+
+// VerbatimString is text with a three letter format such as "txt" or "mkd".
+// It is sent as a plain bulk string in RESP2.
+type VerbatimString struct {
+	Format string
+	Text   string
 }
 
-func Maps(val map[any]any) string {
-	resp := fmt.Sprintf("%c%d\r\n", maps, len(val))
-	for k, v := range val {
-		keyEncoded := encodeRandomString(k)
-		valueEncoded := encodeRandomString(v)
-		resp += fmt.Sprintf("%v:%v\r\n", keyEncoded, valueEncoded)
-	}
-	return resp
+// Encode encodes v for the given protocol version, choosing for each RESP3
+// type the RESP2 type Redis falls back to: nil is a null bulk string,
+// booleans are integers, and doubles and big numbers are bulk strings.
+func Encode(v any, version int) string {
+	return string(appendValue(nil, v, version))
 }
 
-func encodeRandomString(val any) string {
-	switch val.(type) {
+// appendValue appends the encoding of v to b.
+func appendValue(b []byte, v any, version int) []byte {
+	resp3 := version >= RESP3
+
+	switch v := v.(type) {
+	case nil:
+		if resp3 {
+			return appendLine(b, nulls, "")
+		}
+		return appendHeader(b, bulkString, -1)
 	case string:
-		return SimpleString(val.(string))
+		return appendBulk(b, v)
+	case []byte:
+		return appendBulk(b, string(v))
 	case int:
-		return Integer(val.(int))
+		return appendHeader(b, integer, v)
+	case int64:
+		return appendHeader(b, integer, int(v))
 	case bool:
-		return Booleans(val.(bool))
-	case error:
-		return ErrorString(val.(error).Error())
+		switch {
+		case resp3 && v:
+			return appendLine(b, booleans, "t")
+		case resp3:
+			return appendLine(b, booleans, "f")
+		case v:
+			return appendHeader(b, integer, 1)
+		default:
+			return appendHeader(b, integer, 0)
+		}
 	case float64:
-		return Doubles(val.(float64))
-	case big.Int:
-		return BigNumbers(val.(big.Int))
-	case []error:
-		return BulkErrors(val.([]error))
-	case map[any]any:
-		return Maps(val.(map[any]any))
+		if resp3 {
+			return appendLine(b, doubles, formatDouble(v))
+		}
+		return appendBulk(b, formatDouble(v))
+	case *big.Int:
+		if resp3 {
+			return appendLine(b, bigNumbers, v.String())
+		}
+		return appendBulk(b, v.String())
+	case error:
+		return appendLine(b, errorString, v.Error())
 	case []any:
-		return Array(val.([]any))
+		return appendAggregate(b, array, v, version)
+	case [2]any:
+		return appendAggregate(b, array, v[:], version)
+	case Map:
+		if resp3 {
+			b = appendHeader(b, maps, len(v)/2)
+			return appendElems(b, v, version)
+		}
+		return appendAggregate(b, array, v, version)
+	case map[any]any:
+		pairs := make(Map, 0, 2*len(v))
+		for k, val := range v {
+			pairs = append(pairs, k, val)
+		}
+		return appendValue(b, pairs, version)
+	case Set:
+		if resp3 {
+			return appendAggregate(b, sets, v, version)
+		}
+		return appendAggregate(b, array, v, version)
+	case VerbatimString:
+		if resp3 {
+			return appendVerbatim(b, v.Text, v.Format)
+		}
+		return appendBulk(b, v.Text)
 	default:
-		return Nulls()
+		return appendValue(b, nil, version)
+	}
+}
+
+// appendLine appends a type whose payload runs to the end of the line.
+func appendLine(b []byte, prefix byte, s string) []byte {
+	b = append(b, prefix)
+	b = append(b, s...)
+	return append(b, crlf...)
+}
+
+// appendHeader appends a prefix followed by a number, which is an integer or
+// the header of a length-prefixed type.
+func appendHeader(b []byte, prefix byte, n int) []byte {
+	b = append(b, prefix)
+	b = strconv.AppendInt(b, int64(n), 10)
+	return append(b, crlf...)
+}
+
+func appendBulk(b []byte, s string) []byte {
+	b = appendHeader(b, bulkString, len(s))
+	b = append(b, s...)
+	return append(b, crlf...)
+}
+
+func appendVerbatim(b []byte, s, format string) []byte {
+	if format == "" {
+		format = "txt"
+	}
+
+	b = appendHeader(b, verbatim, len(format)+1+len(s))
+	b = append(b, format...)
+	b = append(b, ':')
+	b = append(b, s...)
+	return append(b, crlf...)
+}
+
+// appendAggregate appends a with the given aggregate type prefix, as arrays,
+// sets and pushes share the same element encoding.
+func appendAggregate(b []byte, prefix byte, a []any, version int) []byte {
+	b = appendHeader(b, prefix, len(a))
+	return appendElems(b, a, version)
+}
+
+func appendElems(b []byte, a []any, version int) []byte {
+	for _, v := range a {
+		b = appendValue(b, v, version)
 	}
+	return b
 }
 
-func Attributes(val map[string]interface{}) string {
-	return fmt.Sprintf("%c%d\r\n", attributes, len(val))
+// NullArray is the RESP2 null reply used where an array was expected.
+func NullArray() string {
+	return string(appendHeader(nil, array, -1))
+}
+
+func Nulls() string {
+	return string(appendLine(nil, nulls, ""))
+}
+
+func Booleans(val bool) string {
+	return Encode(val, RESP3)
+}
+
+func Doubles(val float64) string {
+	return Encode(val, RESP3)
+}
+
+// formatDouble writes val the way RESP3 spells doubles, including inf, -inf
+// and nan.
+func formatDouble(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "inf"
+	case math.IsInf(val, -1):
+		return "-inf"
+	case math.IsNaN(val):
+		return "nan"
+	default:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	}
+}
+
+func BigNumbers(val *big.Int) string {
+	return Encode(val, RESP3)
+}
+
+// BulkErrors encodes an error whose message may contain CR or LF.
+func BulkErrors(val string) string {
+	b := appendHeader(nil, bulkErrors, len(val))
+	b = append(b, val...)
+	return string(append(b, crlf...))
+}
+
+// Verbatim encodes val as a verbatim string. encoding is the three letter
+// format and defaults to "txt".
+func Verbatim(val, encoding string) string {
+	return string(appendVerbatim(nil, val, encoding))
+}
+
+// Maps encodes val as a RESP3 map. Go maps are unordered, so use Encode with
+// a Map when the order of the keys matters.
+func Maps(val map[any]any) string {
+	return Encode(val, RESP3)
+}
+
+// Attributes encodes an attribute block, which annotates the reply written
+// right after it.
+func Attributes(val map[any]any) string {
+	b := appendHeader(nil, attributes, len(val))
+	for k, v := range val {
+		b = appendValue(b, k, RESP3)
+		b = appendValue(b, v, RESP3)
+	}
+	return string(b)
 }
 
-func Sets(val map[string]interface{}) string {
-	return fmt.Sprintf("%c%d\r\n", sets, len(val))
+func Sets(val []any) string {
+	return string(appendAggregate(nil, sets, val, RESP3))
 }
 
-func Pushes(val map[string]interface{}) string {
-	return fmt.Sprintf("%c%d\r\n", pushes, len(val))
+// Pushes encodes an out-of-band RESP3 push, such as a Pub/Sub message.
+func Pushes(val []any) string {
+	return string(appendAggregate(nil, pushes, val, RESP3))
 }
@@ -19,3 +19,26 @@ func (a arrayRESP) String() string {
 	}
 	return strings.Join(resp, "|")
 }
+
+// Strings returns the elements of an array, set or push as strings, or resp
+// itself as the only element otherwise. Unlike String it keeps elements that
+// contain the "|" separator intact.
+func Strings(resp RESP) []string {
+	var elems []RESP
+	switch r := resp.(type) {
+	case arrayRESP:
+		elems = r
+	case setRESP:
+		elems = r
+	case pushRESP:
+		elems = r
+	default:
+		return []string{resp.String()}
+	}
+
+	res := make([]string, len(elems))
+	for i, e := range elems {
+		res[i] = e.String()
+	}
+	return res
+}
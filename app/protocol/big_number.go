@@ -0,0 +1,17 @@
+package protocol
+
+import "math/big"
+
+type bigNumberRESP big.Int
+
+func (b *bigNumberRESP) String() string {
+	return (*big.Int)(b).String()
+}
+
+func (b *bigNumberRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (b *bigNumberRESP) IsMap() bool {
+	return false
+}
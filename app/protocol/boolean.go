@@ -0,0 +1,17 @@
+package protocol
+
+import "strconv"
+
+type booleanRESP bool
+
+func (b booleanRESP) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+func (b booleanRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (b booleanRESP) IsMap() bool {
+	return false
+}
@@ -0,0 +1,15 @@
+package protocol
+
+type bulkErrorRESP string
+
+func (e bulkErrorRESP) String() string {
+	return string(e)
+}
+
+func (e bulkErrorRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (e bulkErrorRESP) IsMap() bool {
+	return false
+}
@@ -0,0 +1,15 @@
+package protocol
+
+type doubleRESP float64
+
+func (d doubleRESP) String() string {
+	return formatDouble(float64(d))
+}
+
+func (d doubleRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (d doubleRESP) IsMap() bool {
+	return false
+}
@@ -0,0 +1,148 @@
+package protocol
+
+import "strings"
+
+var errUnbalancedQuotes = protocolError("unbalanced quotes in request")
+
+// parseInline reads a request typed as a single line, as telnet and netcat
+// send them. Blank lines are skipped.
+func (p *parser) parseInline() (RESP, error) {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := splitArgs(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		res := make(arrayRESP, len(args))
+		for i, arg := range args {
+			res[i] = bulkStringRESP(arg)
+		}
+		return res, nil
+	}
+}
+
+// splitArgs splits line into arguments the way redis-cli does: they are
+// separated by whitespace and may be quoted. Double quotes support the \n, \r,
+// \t, \b, \a and \xHH escapes, single quotes only \'. A closing quote must be
+// followed by whitespace or the end of the line.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	for i := 0; ; {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var (
+			arg        strings.Builder
+			inDq, inSq bool
+			done       bool
+		)
+		for !done {
+			if i == len(line) {
+				if inDq || inSq {
+					return nil, errUnbalancedQuotes
+				}
+				break
+			}
+
+			c := line[i]
+			switch {
+			case inDq:
+				switch {
+				case c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					arg.WriteByte(hexValue(line[i+2])<<4 | hexValue(line[i+3]))
+					i += 3
+				case c == '\\' && i+1 < len(line):
+					i++
+					arg.WriteByte(unescape(line[i]))
+				case c == '"':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				default:
+					arg.WriteByte(c)
+				}
+			case inSq:
+				switch {
+				case c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					arg.WriteByte('\'')
+				case c == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				default:
+					arg.WriteByte(c)
+				}
+			default:
+				switch {
+				case isSpace(c):
+					done = true
+				case c == '"':
+					inDq = true
+				case c == '\'':
+					inSq = true
+				default:
+					arg.WriteByte(c)
+				}
+			}
+			i++
+		}
+		args = append(args, arg.String())
+	}
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
+
+// unescape returns the character a backslash followed by c stands for inside
+// double quotes.
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	default:
+		return c
+	}
+}
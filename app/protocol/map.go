@@ -0,0 +1,29 @@
+package protocol
+
+import "strings"
+
+// mapRESP keeps the key/value pairs of a map in the order they were read.
+type mapRESP [][2]RESP
+
+func (m mapRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (m mapRESP) IsMap() bool {
+	return true
+}
+
+func (m mapRESP) String() string {
+	var resp []string
+	for _, kv := range m {
+		resp = append(resp, kv[0].String(), kv[1].String())
+	}
+	return strings.Join(resp, "|")
+}
+
+// attributedRESP is a value preceded by an attribute block. It reads as the
+// value itself.
+type attributedRESP struct {
+	RESP
+	attributes mapRESP
+}
@@ -0,0 +1,15 @@
+package protocol
+
+type nullRESP struct{}
+
+func (n nullRESP) String() string {
+	return ""
+}
+
+func (n nullRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (n nullRESP) IsMap() bool {
+	return false
+}
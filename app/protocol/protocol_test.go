@@ -0,0 +1,160 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"math/big"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, s string) RESP {
+	t.Helper()
+
+	r, err := ParseRequest(bufio.NewReader(strings.NewReader(s)))
+	require.NoError(t, err)
+	return r
+}
+
+func parseValue(t *testing.T, s string) RESP {
+	t.Helper()
+
+	r, err := ParseValue(bufio.NewReader(strings.NewReader(s)))
+	require.NoError(t, err)
+	return r
+}
+
+func TestEncodeByVersion(t *testing.T) {
+	for name, tc := range map[string]struct {
+		val   any
+		resp2 string
+		resp3 string
+	}{
+		"null":      {nil, "$-1\r\n", "_\r\n"},
+		"empty":     {[]byte{}, "$0\r\n\r\n", "$0\r\n\r\n"},
+		"empty str": {"", "$0\r\n\r\n", "$0\r\n\r\n"},
+		"bool":      {true, ":1\r\n", "#t\r\n"},
+		"double":    {1.5, "$3\r\n1.5\r\n", ",1.5\r\n"},
+		"inf":       {math.Inf(-1), "$4\r\n-inf\r\n", ",-inf\r\n"},
+		"big":       {big.NewInt(12), "$2\r\n12\r\n", "(12\r\n"},
+		"map":       {Map{"a", 1}, "*2\r\n$1\r\na\r\n:1\r\n", "%1\r\n$1\r\na\r\n:1\r\n"},
+		"set":       {Set{"a"}, "*1\r\n$1\r\na\r\n", "~1\r\n$1\r\na\r\n"},
+		"verbatim":  {VerbatimString{"txt", "hi"}, "$2\r\nhi\r\n", "=6\r\ntxt:hi\r\n"},
+		"nested":    {[]any{nil, []any{false}}, "*2\r\n$-1\r\n*1\r\n:0\r\n", "*2\r\n_\r\n*1\r\n#f\r\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.resp2, Encode(tc.val, RESP2))
+			assert.Equal(t, tc.resp3, Encode(tc.val, RESP3))
+		})
+	}
+}
+
+func TestParseRESP3Types(t *testing.T) {
+	assert.Equal(t, nullRESP{}, parseValue(t, "_\r\n"))
+	assert.Equal(t, nullRESP{}, parseValue(t, "$-1\r\n"))
+	assert.Equal(t, booleanRESP(false), parseValue(t, "#f\r\n"))
+	assert.Equal(t, doubleRESP(-2.5), parseValue(t, ",-2.5\r\n"))
+	assert.True(t, math.IsInf(float64(parseValue(t, ",inf\r\n").(doubleRESP)), 1))
+	assert.Equal(t, "3492890328409238509324850943850943825024385", parseValue(t, "(3492890328409238509324850943850943825024385\r\n").String())
+	assert.Equal(t, bulkErrorRESP("SYNTAX\r\nbad"), parseValue(t, "!11\r\nSYNTAX\r\nbad\r\n"))
+	assert.Equal(t, verbatimRESP{format: "txt", text: "Some string"}, parseValue(t, "=15\r\ntxt:Some string\r\n"))
+
+	m := parseValue(t, "%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n")
+	assert.True(t, m.IsMap())
+	assert.Equal(t, "first|1|second|2", m.String())
+
+	assert.Equal(t, []string{"a", "b"}, Strings(parseValue(t, "~2\r\n+a\r\n+b\r\n")))
+	assert.Equal(t, []string{"message", "ch"}, Strings(parseValue(t, ">2\r\n+message\r\n+ch\r\n")))
+
+	a := parseValue(t, "|1\r\n+ttl\r\n:3600\r\n:2039123\r\n")
+	assert.Equal(t, "2039123", a.String())
+}
+
+func TestParseBulkStringIsBinarySafe(t *testing.T) {
+	r := parse(t, "*2\r\n$3\r\nGET\r\n$5\r\na\r\nb|\r\n")
+	assert.Equal(t, []string{"GET", "a\r\nb|"}, Strings(r))
+	assert.Equal(t, []string{"", "x"}, Strings(parse(t, "*2\r\n$0\r\n\r\n$1\r\nx\r\n")))
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		val  any
+		want string
+	}{
+		{Map{"k", "v"}, "k|v"},
+		{Set{"x", "y"}, "x|y"},
+		{VerbatimString{"mkd", "# title"}, "# title"},
+		{"a\r\nb", "a\r\nb"},
+		{2.25, "2.25"},
+	} {
+		assert.Equal(t, tc.want, parseValue(t, Encode(tc.val, RESP3)).String())
+	}
+}
+
+func TestParseRequestIsBulkStrings(t *testing.T) {
+	for _, req := range []string{
+		"+PING\r\n",
+		"$4\r\nPING\r\n",
+		"~2\r\n+PING\r\n:1\r\n",
+		">2\r\n$4\r\nECHO\r\n#t\r\n",
+		"*1\r\n*1\r\n$4\r\nPING\r\n",
+	} {
+		_, err := ParseRequest(bufio.NewReader(strings.NewReader(req)))
+		assert.ErrorIs(t, err, ErrProtocol, req)
+		assert.ErrorContains(t, err, "expected '$'", req)
+	}
+}
+
+func TestParseRequestSkipsEmptyArrays(t *testing.T) {
+	assert.Equal(t, []string{"PING"}, Strings(parse(t, "*0\r\n*-1\r\n*1\r\n$4\r\nPING\r\n")))
+}
+
+func TestParseValueNestingLimit(t *testing.T) {
+	_, err := ParseValue(bufio.NewReader(strings.NewReader(strings.Repeat("~1\r\n", 1<<20))))
+	assert.ErrorIs(t, err, ErrProtocol)
+
+	nested := strings.Repeat("*1\r\n", maxNesting-1) + ":1\r\n"
+	assert.Equal(t, "1", parseValue(t, nested).String())
+}
+
+func TestParseInline(t *testing.T) {
+	for line, want := range map[string][]string{
+		"PING\r\n":                       {"PING"},
+		"set  k   v\n":                   {"set", "k", "v"},
+		"\r\n\n  \r\nGET k\r\n":          {"GET", "k"},
+		`SET k "a b\tc\x41\"d"` + "\r\n": {"SET", "k", "a b\tcA\"d"},
+		`SET k 'it\'s "x"'` + "\r\n":     {"SET", "k", `it's "x"`},
+		`SET k ""` + "\r\n":              {"SET", "k", ""},
+		`SET a"b c" x` + "\r\n":          {"SET", "ab c", "x"},
+		`ECHO "\q\\"` + "\r\n":           {"ECHO", `q\`},
+	} {
+		assert.Equal(t, want, Strings(parse(t, line)), line)
+	}
+}
+
+func TestParseInlineUnbalancedQuotes(t *testing.T) {
+	for _, line := range []string{
+		`SET k "v` + "\r\n",
+		`SET k 'v` + "\r\n",
+		`SET k "v"x` + "\r\n",
+		`SET k 'v'x` + "\r\n",
+	} {
+		_, err := ParseRequest(bufio.NewReader(strings.NewReader(line)))
+		assert.ErrorIs(t, err, errUnbalancedQuotes, line)
+	}
+}
+
+func TestLengthHeadersDoNotPreallocate(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ParseRequest(bufio.NewReader(strings.NewReader("*1048576\r\n$536870911\r\nabc")))
+	runtime.ReadMemStats(&after)
+
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(4<<20))
+}
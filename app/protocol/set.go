@@ -0,0 +1,30 @@
+package protocol
+
+type setRESP []RESP
+
+func (s setRESP) IsArray() (string, bool) {
+	return "|", true
+}
+
+func (s setRESP) IsMap() bool {
+	return false
+}
+
+func (s setRESP) String() string {
+	return arrayRESP(s).String()
+}
+
+// pushRESP is an out-of-band push. Its elements read like an array's.
+type pushRESP []RESP
+
+func (p pushRESP) IsArray() (string, bool) {
+	return "|", true
+}
+
+func (p pushRESP) IsMap() bool {
+	return false
+}
+
+func (p pushRESP) String() string {
+	return arrayRESP(p).String()
+}
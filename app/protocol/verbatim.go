@@ -0,0 +1,20 @@
+package protocol
+
+// verbatimRESP is a verbatim string; format is its three letter type, such as
+// "txt" or "mkd".
+type verbatimRESP struct {
+	format string
+	text   string
+}
+
+func (v verbatimRESP) String() string {
+	return v.text
+}
+
+func (v verbatimRESP) IsArray() (string, bool) {
+	return "", false
+}
+
+func (v verbatimRESP) IsMap() bool {
+	return false
+}
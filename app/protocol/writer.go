@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+)
+
+// writerBufferSize is big enough for the replies of a typical pipeline batch.
+// Larger replies are written out as the buffer fills up.
+const writerBufferSize = 16 << 10
+
+// Writer encodes replies straight into a buffer in the connection's protocol
+// version, so that large replies are not built up as strings first and a
+// whole pipeline batch goes out with a single write on Flush.
+//
+// Write errors are sticky: the Write methods don't report them, and Flush
+// returns the first one.
+type Writer struct {
+	buf     *bufio.Writer
+	version int
+}
+
+// NewWriter returns a RESP2 writer buffering replies for w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{buf: bufio.NewWriterSize(w, writerBufferSize), version: RESP2}
+}
+
+// Version is the protocol version replies are encoded in.
+func (w *Writer) Version() int {
+	return w.version
+}
+
+// SetVersion switches the protocol version of the replies that follow.
+func (w *Writer) SetVersion(version int) {
+	w.version = version
+}
+
+// Buffered returns the number of bytes waiting for Flush.
+func (w *Writer) Buffered() int {
+	return w.buf.Buffered()
+}
+
+// Flush writes the buffered replies to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.buf.Flush()
+}
+
+func (w *Writer) write(b []byte) {
+	_, _ = w.buf.Write(b)
+}
+
+// WriteRaw writes an already encoded reply.
+func (w *Writer) WriteRaw(resp string) {
+	_, _ = w.buf.WriteString(resp)
+}
+
+// WriteArrayHeader starts an array of n elements, which the caller writes
+// next.
+func (w *Writer) WriteArrayHeader(n int) {
+	w.write(appendHeader(w.buf.AvailableBuffer(), array, n))
+}
+
+// WriteMapHeader starts a map of n key/value pairs, which the caller writes
+// next. In RESP2 it starts a flat array of 2n elements.
+func (w *Writer) WriteMapHeader(n int) {
+	if w.version >= RESP3 {
+		w.write(appendHeader(w.buf.AvailableBuffer(), maps, n))
+		return
+	}
+	w.WriteArrayHeader(2 * n)
+}
+
+// WriteSetHeader starts a set of n elements, an array in RESP2.
+func (w *Writer) WriteSetHeader(n int) {
+	if w.version >= RESP3 {
+		w.write(appendHeader(w.buf.AvailableBuffer(), sets, n))
+		return
+	}
+	w.WriteArrayHeader(n)
+}
+
+// WritePushHeader starts a push of n elements, an array in RESP2.
+func (w *Writer) WritePushHeader(n int) {
+	if w.version >= RESP3 {
+		w.write(appendHeader(w.buf.AvailableBuffer(), pushes, n))
+		return
+	}
+	w.WriteArrayHeader(n)
+}
+
+// WriteBulk writes s as a bulk string.
+func (w *Writer) WriteBulk(s string) {
+	w.write(appendBulk(w.buf.AvailableBuffer(), s))
+}
+
+func (w *Writer) WriteInt(n int) {
+	w.write(appendHeader(w.buf.AvailableBuffer(), integer, n))
+}
+
+func (w *Writer) WriteSimple(s string) {
+	w.write(appendLine(w.buf.AvailableBuffer(), simpleString, s))
+}
+
+func (w *Writer) WriteError(s string) {
+	w.write(appendLine(w.buf.AvailableBuffer(), errorString, s))
+}
+
+// WriteNull writes the null reply: a null bulk string in RESP2.
+func (w *Writer) WriteNull() {
+	w.write(appendValue(w.buf.AvailableBuffer(), nil, w.version))
+}
+
+// WriteNullArray writes the null reply of commands that otherwise reply with
+// an array, which RESP2 tells apart from a null bulk string.
+func (w *Writer) WriteNullArray() {
+	if w.version >= RESP3 {
+		w.WriteNull()
+		return
+	}
+	w.write(appendHeader(w.buf.AvailableBuffer(), array, -1))
+}
+
+// WriteValue encodes v the way Encode does.
+func (w *Writer) WriteValue(v any) {
+	w.write(appendValue(w.buf.AvailableBuffer(), v, w.version))
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterMatchesEncode(t *testing.T) {
+	for _, version := range []int{RESP2, RESP3} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.SetVersion(version)
+
+		w.WriteMapHeader(2)
+		w.WriteBulk("a")
+		w.WriteInt(1)
+		w.WriteBulk("b")
+		w.WriteNull()
+		w.WriteValue(Set{"x", 2.5})
+		require.NoError(t, w.Flush())
+
+		want := Encode(Map{"a", 1, "b", nil}, version) + Encode(Set{"x", 2.5}, version)
+		assert.Equal(t, want, buf.String())
+	}
+}
+
+func TestWriterBuffersUntilFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	w.WriteSimple("OK")
+	w.WriteError("ERR no")
+	w.WriteNullArray()
+	w.WriteBulk("")
+	assert.Zero(t, buf.Len())
+
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "+OK\r\n-ERR no\r\n*-1\r\n$0\r\n\r\n", buf.String())
+	assert.Zero(t, w.Buffered())
+}
+
+func benchmarkItems(n int) []any {
+	items := make([]any, n)
+	for i := range items {
+		items[i] = "element-" + strconv.Itoa(i)
+	}
+	return items
+}
+
+// BenchmarkEncodeArray builds the whole reply as a string before writing it,
+// the way replies returned by handlers are sent.
+func BenchmarkEncodeArray(b *testing.B) {
+	items := benchmarkItems(10000)
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _ = io.WriteString(io.Discard, Array(items))
+	}
+}
+
+// BenchmarkWriterArray streams the same reply through a Writer, the way
+// LRANGE and XRANGE do.
+func BenchmarkWriterArray(b *testing.B) {
+	items := benchmarkItems(10000)
+	w := NewWriter(io.Discard)
+	b.ReportAllocs()
+
+	for b.Loop() {
+		w.WriteArrayHeader(len(items))
+		for _, v := range items {
+			w.WriteBulk(v.(string))
+		}
+		_ = w.Flush()
+	}
+}
+
+// BenchmarkWriterPipeline answers a batch of small replies with one flush.
+func BenchmarkWriterPipeline(b *testing.B) {
+	w := NewWriter(io.Discard)
+	b.ReportAllocs()
+
+	for b.Loop() {
+		for i := range 100 {
+			w.WriteSimple("OK")
+			w.WriteInt(i)
+		}
+		_ = w.Flush()
+	}
+}
@@ -0,0 +1,288 @@
+// Package server runs the executor behind a TCP listener. Each Server has
+// databases of its own, so that several can run in one process, as test
+// fixtures do.
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cache"
+	"github.com/codecrafters-io/redis-starter-go/app/executor"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+const (
+	// DefaultAddr is the address servers listen on unless configured
+	// otherwise.
+	DefaultAddr = "0.0.0.0:6379"
+	// DefaultDatabases is the number of databases unless configured
+	// otherwise.
+	DefaultDatabases = 16
+)
+
+var errNotStarted = errors.New("server not started")
+
+// Config is the configuration of a Server. Zero fields take their defaults.
+type Config struct {
+	// Addr is the TCP address to listen on. Tests use "127.0.0.1:0" to get
+	// a free port, which Addr then reports.
+	Addr string
+	// Limits bounds the size of requests.
+	Limits protocol.Limits
+	// Databases is the number of databases clients can SELECT.
+	Databases int
+	// MaxMemory, MaxMemoryPolicy and MaxMemorySamples configure eviction,
+	// which is off when MaxMemory is 0.
+	MaxMemory        int
+	MaxMemoryPolicy  executor.EvictionPolicy
+	MaxMemorySamples int
+	// MaxMemoryClients bounds the replies waiting to be read by all clients,
+	// as in executor.Config.
+	MaxMemoryClients int
+	// SlowLogSlowerThan, SlowLogMaxLen and LatencyMonitorThreshold configure
+	// the slow log and the latency monitor, as in executor.Config.
+	SlowLogSlowerThan       time.Duration
+	SlowLogMaxLen           int
+	LatencyMonitorThreshold time.Duration
+	// NormalOutputLimit and PubSubOutputLimit disconnect clients that fall
+	// too far behind reading their replies, as in executor.Config.
+	NormalOutputLimit executor.OutputBufferLimit
+	PubSubOutputLimit executor.OutputBufferLimit
+}
+
+// withDefaults fills in the zero fields of c.
+func (c Config) withDefaults() Config {
+	if c.Addr == "" {
+		c.Addr = DefaultAddr
+	}
+	if c.Databases <= 0 {
+		c.Databases = DefaultDatabases
+	}
+	if c.Limits.MaxBulkLen == 0 {
+		c.Limits.MaxBulkLen = protocol.DefaultLimits.MaxBulkLen
+	}
+	if c.Limits.MaxMultiBulkLen == 0 {
+		c.Limits.MaxMultiBulkLen = protocol.DefaultLimits.MaxMultiBulkLen
+	}
+	if c.Limits.MaxQueryBuffer == 0 {
+		c.Limits.MaxQueryBuffer = protocol.DefaultLimits.MaxQueryBuffer
+	}
+	return c
+}
+
+// Server serves its databases over TCP.
+type Server struct {
+	config Config
+	dbs    []cache.Cache
+	exec   *executor.Executor
+
+	listener net.Listener
+	// accepted is closed when the accept loop returns, after which conns
+	// tracks every goroutine serving a connection.
+	accepted chan struct{}
+	conns    sync.WaitGroup
+
+	shutdownOnce sync.Once
+	done         chan struct{}
+}
+
+// New returns a server with empty databases. It does not listen until Start.
+func New(config Config) *Server {
+	config = config.withDefaults()
+
+	dbs := make([]cache.Cache, config.Databases)
+	for i := range dbs {
+		dbs[i] = cache.New()
+	}
+	return &Server{
+		config: config,
+		dbs:    dbs,
+		exec: executor.New(dbs, executor.Config{
+			MaxMemory:               config.MaxMemory,
+			MaxMemoryPolicy:         config.MaxMemoryPolicy,
+			MaxMemorySamples:        config.MaxMemorySamples,
+			MaxMemoryClients:        config.MaxMemoryClients,
+			SlowLogSlowerThan:       config.SlowLogSlowerThan,
+			SlowLogMaxLen:           config.SlowLogMaxLen,
+			LatencyMonitorThreshold: config.LatencyMonitorThreshold,
+			NormalOutputLimit:       config.NormalOutputLimit,
+			PubSubOutputLimit:       config.PubSubOutputLimit,
+		}),
+		accepted: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start listens on the configured address and serves connections in the
+// background. The server shuts down once ctx is done or a client runs
+// SHUTDOWN, without waiting for the connections to be closed; call Shutdown
+// to wait for them.
+func (s *Server) Start(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+
+	go s.accept()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.shutdown()
+		case <-s.exec.ShutdownRequested():
+			s.shutdown()
+		case <-s.done:
+		}
+	}()
+	return nil
+}
+
+// RegisterCommand adds a command to this server only, as
+// executor.Executor.RegisterCommand does. It may be called while the server is
+// running.
+func (s *Server) RegisterCommand(name string, arity int, flags executor.CommandFlags, fn executor.CommandFunc) error {
+	return s.exec.RegisterCommand(name, arity, flags, fn)
+}
+
+// Addr is the address the server listens on, or "" before Start.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Done is closed once the server has started shutting down.
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Shutdown stops accepting connections and closes the clients once their
+// running command has finished, then waits until every connection has been
+// served or ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.listener == nil {
+		return errNotStarted
+	}
+	s.shutdown()
+
+	served := make(chan struct{})
+	go func() {
+		<-s.accepted
+		s.conns.Wait()
+		close(served)
+	}()
+
+	select {
+	case <-served:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) shutdown() {
+	s.shutdownOnce.Do(func() {
+		close(s.done)
+		if err := s.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Println(err)
+		}
+		s.exec.Close()
+	})
+}
+
+// accept serves connections until the listener is closed. Other errors, such
+// as running out of file descriptors, are retried with a growing delay.
+func (s *Server) accept() {
+	defer close(s.accepted)
+
+	var delay time.Duration
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+
+			delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+			log.Printf("accepting connections: %v; retrying in %v", err, delay)
+			select {
+			case <-time.After(delay):
+			case <-s.done:
+				return
+			}
+			continue
+		}
+		delay = 0
+
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn runs the requests arriving on conn until the peer hangs up or
+// sends something unreadable. Requests that are already buffered run back to
+// back, and their replies go out with a single flush once the buffer is
+// drained. A request breaking the protocol or exceeding limits gets an error
+// reply, after which the connection is closed.
+func (s *Server) serveConn(conn net.Conn) {
+	client := s.exec.NewClient(conn)
+	defer client.Close()
+
+	// A connection accepted while shutting down may have missed the
+	// executor closing its clients.
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	buff := bufio.NewReader(conn)
+	for {
+		res, err := protocol.ParseRequestWithLimits(buff, s.config.Limits)
+		if err != nil {
+			switch {
+			case errors.Is(err, protocol.ErrProtocol):
+				log.Printf("%v from client %s", err, conn.RemoteAddr())
+				client.ProtocolError(err)
+			// killed by another client, or hung up
+			case !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed):
+				log.Println(err)
+			}
+			if err := client.Flush(); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Println(err)
+			}
+			return
+		}
+
+		if err := client.Execute(res); err != nil {
+			log.Println(err)
+		}
+
+		closing := client.CloseAfterReply()
+		if buff.Buffered() > 0 && !closing {
+			continue
+		}
+		if err := client.Flush(); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println(err)
+			}
+			return
+		}
+		if closing {
+			return
+		}
+	}
+}
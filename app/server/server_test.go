@@ -0,0 +1,863 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/executor"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pipelined = 10000
+
+// start runs a server on a free port until the test ends.
+func start(t *testing.T, limits protocol.Limits) *Server {
+	t.Helper()
+
+	srv := New(Config{Addr: "127.0.0.1:0", Limits: limits})
+	require.NoError(t, srv.Start(t.Context()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, srv.Shutdown(ctx))
+	})
+	return srv
+}
+
+// dial starts a server and connects to it.
+func dial(t *testing.T) *net.TCPConn {
+	return dialWithLimits(t, protocol.DefaultLimits)
+}
+
+func dialWithLimits(t *testing.T, limits protocol.Limits) *net.TCPConn {
+	return connect(t, start(t, limits))
+}
+
+func connect(t *testing.T, srv *Server) *net.TCPConn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Second)))
+	return conn.(*net.TCPConn)
+}
+
+func command(args ...string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return b.Bytes()
+}
+
+// send writes reqs in the background, so that a server that only replies
+// after reading everything cannot deadlock the test.
+func send(conn net.Conn, reqs []byte) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(reqs)
+		errc <- err
+	}()
+	return errc
+}
+
+func TestPipelinedPings(t *testing.T) {
+	conn := dial(t)
+	errc := send(conn, bytes.Repeat(command("PING"), pipelined))
+
+	r := bufio.NewReader(conn)
+	for i := range pipelined {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err, "reply %d", i)
+		require.Equal(t, "+PONG\r\n", line, "reply %d", i)
+	}
+	require.NoError(t, <-errc)
+}
+
+func TestPipelinedRepliesKeepOrder(t *testing.T) {
+	conn := dial(t)
+
+	var reqs, want bytes.Buffer
+	for i := range pipelined / 2 {
+		key, val := fmt.Sprintf("pipeline:%d", i), fmt.Sprint(i)
+		reqs.Write(command("SET", key, val))
+		reqs.Write(command("GET", key))
+		fmt.Fprintf(&want, "+OK\r\n$%d\r\n%s\r\n", len(val), val)
+	}
+	errc := send(conn, reqs.Bytes())
+
+	got := make([]byte, want.Len())
+	_, err := io.ReadFull(conn, got)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), string(got))
+	require.NoError(t, <-errc)
+}
+
+func TestEmptyRequestsAreIgnored(t *testing.T) {
+	conn := dial(t)
+	exchange(t, conn, "+PONG\r\n", []byte("*0\r\n*-1\r\n"), command("PING"))
+}
+
+func TestConnectionClosesOnEOF(t *testing.T) {
+	conn := dial(t)
+
+	_, err := conn.Write(bytes.Repeat(command("PING"), 3))
+	require.NoError(t, err)
+	require.NoError(t, conn.CloseWrite())
+
+	// The replies to what was sent still arrive, then the server hangs up
+	// instead of waiting for more.
+	got, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n+PONG\r\n+PONG\r\n", string(got))
+}
+
+func TestProtocolErrorClosesConnection(t *testing.T) {
+	limits := protocol.Limits{MaxBulkLen: 16, MaxMultiBulkLen: 4, MaxQueryBuffer: 48}
+
+	for name, tc := range map[string]struct {
+		req  string
+		want string
+	}{
+		"multibulk length": {"*999999999\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		"bulk length":      {"*2\r\n$4\r\nECHO\r\n$17\r\n", "-ERR Protocol error: invalid bulk length\r\n"},
+		"not a length":     {"*x\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		"query buffer":     {"*3\r\n$3\r\nSET\r\n$16\r\n0123456789abcdef\r\n$16\r\n0123456789abcdef\r\n", "-ERR Protocol error: query buffer limit exceeded\r\n"},
+		"unbalanced":       {"PING\r\nECHO \"x\r\n", "+PONG\r\n-ERR Protocol error: unbalanced quotes in request\r\n"},
+		"null request":     {"*-1\r\n*0\r\n*x\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		"nested request":   {"*1\r\n*1\r\n$4\r\nPING\r\n", "-ERR Protocol error: expected '$', got '*'\r\n"},
+		"inline argument":  {"*2\r\n$4\r\nECHO\r\n+x\r\n", "-ERR Protocol error: expected '$', got '+'\r\n"},
+		"simple string":    {"+PING\r\n", "-ERR Protocol error: expected '$', got '+'\r\n"},
+		"nested sets":      {strings.Repeat("~1\r\n", 1<<10), "-ERR Protocol error: expected '$', got '~'\r\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn := dialWithLimits(t, limits)
+
+			_, err := conn.Write([]byte(tc.req))
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(conn)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+// registerDouble adds DOUBLE key, which replies with twice the integer stored
+// at key.
+func registerDouble(srv *Server) error {
+	return srv.RegisterCommand("DOUBLE", 2, executor.FlagReadonly|executor.FlagFast, func(ctx *executor.Context, args []string) error {
+		v, ok := ctx.Keyspace.Get(args[0])
+		if !ok {
+			return errors.New("ERR no such key")
+		}
+
+		n, err := strconv.Atoi(v.(string))
+		if err != nil {
+			return errors.New("ERR value is not an integer or out of range")
+		}
+		ctx.Reply.WriteInt(2 * n)
+		return nil
+	})
+}
+
+func TestRegisteredCommand(t *testing.T) {
+	srv, other := start(t, protocol.DefaultLimits), start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+
+	// Commands can be registered while clients are connected.
+	require.NoError(t, registerDouble(srv))
+	assert.Error(t, registerDouble(srv), "registered twice")
+	assert.Error(t, srv.RegisterCommand("get", 2, 0, func(*executor.Context, []string) error { return nil }))
+
+	reqs := [][]byte{
+		command("SET", "double:n", "21"),
+		command("double", "double:n"),
+		command("DOUBLE", "double:missing"),
+		command("DOUBLE"),
+	}
+	errc := send(conn, bytes.Join(reqs, nil))
+
+	want := "+OK\r\n:42\r\n-ERR no such key\r\n" +
+		"-ERR wrong number of arguments for 'double' command\r\n"
+	got := make([]byte, len(want))
+	_, err := io.ReadFull(conn, got)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+	require.NoError(t, <-errc)
+
+	exchange(t, connect(t, other), "-ERR unknown command 'double', with args beginning with: 'n' \r\n", command("DOUBLE", "n"))
+}
+
+func TestCommand(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+
+	// Registered commands are counted.
+	r := bufio.NewReader(conn)
+	_, err := conn.Write(command("COMMAND", "COUNT"))
+	require.NoError(t, err)
+	count, err := r.ReadString('\n')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSuffix(count[1:], "\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, registerDouble(srv))
+	exchange(t, conn, ":"+strconv.Itoa(n+1)+"\r\n", command("COMMAND", "COUNT"))
+
+	exchange(t, conn, "*2\r\n*10\r\n$3\r\nget\r\n:2\r\n*2\r\n$8\r\nreadonly\r\n$4\r\nfast\r\n:1\r\n:1\r\n:1\r\n"+
+		"*3\r\n$7\r\n@string\r\n$5\r\n@read\r\n$5\r\n@fast\r\n*0\r\n*0\r\n*0\r\n$-1\r\n",
+		command("COMMAND", "INFO", "get", "nope"))
+
+	exchange(t, conn, "*2\r\n$3\r\nget\r\n*6\r\n$7\r\nsummary\r\n$34\r\nReturns the string value of a key.\r\n"+
+		"$5\r\nsince\r\n$5\r\n1.0.0\r\n$5\r\ngroup\r\n$6\r\nstring\r\n",
+		command("COMMAND", "DOCS", "GET"))
+
+	// There are no sorted set commands, so GEOSEARCHSTORE and BITOP stand in
+	// for the commands with several keys.
+	exchange(t, conn, "*1\r\n$1\r\nk\r\n"+
+		"*1\r\n$1\r\nl\r\n"+
+		"*2\r\n$1\r\na\r\n$1\r\nb\r\n"+
+		"*2\r\n$3\r\ndst\r\n$3\r\nsrc\r\n"+
+		"*3\r\n$3\r\ndst\r\n$1\r\na\r\n$1\r\nb\r\n",
+		command("COMMAND", "GETKEYS", "SET", "k", "v", "PX", "100"),
+		command("COMMAND", "GETKEYS", "BLPOP", "l", "0"),
+		command("COMMAND", "GETKEYS", "XREAD", "COUNT", "2", "STREAMS", "a", "b", "0", "0"),
+		command("COMMAND", "GETKEYS", "GEOSEARCHSTORE", "dst", "src", "FROMMEMBER", "m", "BYRADIUS", "1", "km"),
+		command("COMMAND", "GETKEYS", "BITOP", "AND", "dst", "a", "b"))
+
+	exchange(t, conn, "-ERR The command has no key arguments\r\n-ERR Invalid command specified\r\n"+
+		"-ERR Invalid number of arguments specified for command\r\n",
+		command("COMMAND", "GETKEYS", "PING"), command("COMMAND", "GETKEYS", "nope"), command("COMMAND", "GETKEYS", "SET", "k"))
+	exchange(t, conn, "-ERR wrong number of arguments for 'blpop' command\r\n", command("BLPOP", "a", "b", "0"))
+}
+
+func TestServersAreIsolated(t *testing.T) {
+	a, b := connect(t, start(t, protocol.DefaultLimits)), connect(t, start(t, protocol.DefaultLimits))
+
+	_, err := a.Write(command("SET", "isolated", "a"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(a).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "+OK\r\n", line)
+
+	_, err = b.Write(command("GET", "isolated"))
+	require.NoError(t, err)
+	line, err = bufio.NewReader(b).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "$-1\r\n", line)
+
+	// Each server numbers its own clients.
+	for _, conn := range []net.Conn{a, b} {
+		exchange(t, conn, ":1\r\n", command("CLIENT", "ID"))
+	}
+}
+
+func TestShutdownClosesClients(t *testing.T) {
+	srv := New(Config{Addr: "127.0.0.1:0"})
+	require.NoError(t, srv.Start(t.Context()))
+	conn := connect(t, srv)
+
+	// A client blocked without a timeout doesn't hold up the shutdown.
+	_, err := conn.Write(command("BLPOP", "shutdown:list", "0"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	_, err = net.Dial("tcp", srv.Addr())
+	assert.Error(t, err)
+}
+
+func TestShutdownCommand(t *testing.T) {
+	srv := New(Config{Addr: "127.0.0.1:0"})
+	require.NoError(t, srv.Start(t.Context()))
+	conn := connect(t, srv)
+
+	_, err := conn.Write(bytes.Join([][]byte{
+		command("SHUTDOWN", "SAVE"),
+		command("SHUTDOWN", "ABORT"),
+		command("SHUTDOWN", "NOSAVE"),
+	}, nil))
+	require.NoError(t, err)
+
+	// SHUTDOWN has no reply when it succeeds.
+	got, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "-ERR Errors trying to SHUTDOWN. Check logs.\r\n-ERR No shutdown in progress.\r\n", string(got))
+
+	select {
+	case <-srv.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("server still running")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+}
+
+// exchange sends reqs and checks that the replies read want.
+func exchange(t *testing.T, conn net.Conn, want string, reqs ...[]byte) {
+	t.Helper()
+
+	_, err := conn.Write(bytes.Join(reqs, nil))
+	require.NoError(t, err)
+
+	got := make([]byte, len(want))
+	_, err = io.ReadFull(conn, got)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+}
+
+func TestSwapDBFailsWatchers(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	a, b := connect(t, srv), connect(t, srv)
+
+	exchange(t, a, "+OK\r\n+OK\r\n", command("SELECT", "1"), command("SET", "k", "1"))
+	exchange(t, b, "+OK\r\n+OK\r\n", command("WATCH", "k"), command("MULTI"))
+	exchange(t, a, "+OK\r\n", command("SWAPDB", "0", "1"))
+
+	// The key b watched in database 0 now has a value.
+	exchange(t, b, "+QUEUED\r\n*-1\r\n$1\r\n1\r\n", command("GET", "k"), command("EXEC"), command("GET", "k"))
+}
+
+func TestSwapDBKeepsBlockedClients(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	list, stream, other := connect(t, srv), connect(t, srv), connect(t, srv)
+
+	_, err := list.Write(command("BLPOP", "l", "0"))
+	require.NoError(t, err)
+	_, err = stream.Write(command("XREAD", "BLOCK", "0", "STREAMS", "s", "$"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	// The stream swapped into database 0 serves the client blocked there.
+	exchange(t, other, "+OK\r\n$3\r\n1-1\r\n+OK\r\n",
+		command("SELECT", "1"), command("XADD", "s", "1-1", "f", "v"), command("SWAPDB", "0", "1"))
+	exchange(t, stream, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n")
+
+	// The list client still waits on database 0, not on the one it was in.
+	exchange(t, other, ":1\r\n+OK\r\n:1\r\n",
+		command("RPUSH", "l", "x"), command("SELECT", "0"), command("RPUSH", "l", "y"))
+	exchange(t, list, "*2\r\n$1\r\nl\r\n$1\r\ny\r\n")
+}
+
+func TestXRead(t *testing.T) {
+	conn := dial(t)
+
+	entries := "*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n"
+	exchange(t, conn, "$3\r\n1-1\r\n*1\r\n*2\r\n$1\r\ns\r\n"+entries+"*-1\r\n",
+		command("XADD", "s", "1-1", "f", "v"), command("XREAD", "STREAMS", "s", "0"), command("XREAD", "STREAMS", "s", "1-1"))
+
+	// Inside EXEC the reply is nested in the array of results.
+	exchange(t, conn, "+OK\r\n+QUEUED\r\n+QUEUED\r\n*2\r\n*1\r\n*2\r\n$1\r\ns\r\n"+entries+"*-1\r\n",
+		command("MULTI"), command("XREAD", "STREAMS", "s", "0"), command("XREAD", "BLOCK", "0", "STREAMS", "s", "$"), command("EXEC"))
+}
+
+func TestTransactions(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+
+	t.Run("exec", func(t *testing.T) {
+		conn := connect(t, srv)
+		exchange(t, conn, "+OK\r\n+QUEUED\r\n+QUEUED\r\n*2\r\n:1\r\n:2\r\n",
+			command("MULTI"), command("RPUSH", "l", "a"), command("RPUSH", "l", "b"), command("EXEC"))
+	})
+
+	t.Run("queueing error aborts exec", func(t *testing.T) {
+		conn := connect(t, srv)
+		exchange(t, conn, "+OK\r\n+QUEUED\r\n-ERR wrong number of arguments for 'get' command\r\n"+
+			"-EXECABORT Transaction discarded because of previous errors.\r\n$-1\r\n",
+			command("MULTI"), command("SET", "aborted", "1"), command("GET"), command("EXEC"), command("GET", "aborted"))
+	})
+
+	t.Run("watched key changed", func(t *testing.T) {
+		a, b := connect(t, srv), connect(t, srv)
+		exchange(t, a, "+OK\r\n+OK\r\n+QUEUED\r\n", command("WATCH", "w"), command("MULTI"), command("SET", "w", "a"))
+		exchange(t, b, "+OK\r\n", command("SET", "w", "b"))
+		exchange(t, a, "*-1\r\n$1\r\nb\r\n", command("EXEC"), command("GET", "w"))
+
+		// EXEC unwatched the key.
+		exchange(t, a, "+OK\r\n+QUEUED\r\n", command("MULTI"), command("SET", "w", "a"))
+		exchange(t, b, "+OK\r\n", command("SET", "w", "b"))
+		exchange(t, a, "*1\r\n+OK\r\n", command("EXEC"))
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		conn := connect(t, srv)
+		exchange(t, conn, "+OK\r\n+QUEUED\r\n+OK\r\n$-1\r\n-ERR EXEC without MULTI\r\n-ERR DISCARD without MULTI\r\n",
+			command("MULTI"), command("SET", "discarded", "1"), command("DISCARD"), command("GET", "discarded"),
+			command("EXEC"), command("DISCARD"))
+	})
+
+	t.Run("watch inside multi", func(t *testing.T) {
+		conn := connect(t, srv)
+		exchange(t, conn, "+OK\r\n-ERR WATCH inside MULTI is not allowed\r\n-ERR MULTI calls can not be nested\r\n+QUEUED\r\n*1\r\n$-1\r\n",
+			command("MULTI"), command("WATCH", "k"), command("MULTI"), command("GET", "k"), command("EXEC"))
+	})
+}
+
+func TestMaxMemory(t *testing.T) {
+	for _, tc := range []struct {
+		policy executor.EvictionPolicy
+		want   string
+	}{
+		{executor.NoEviction, "-OOM command not allowed when used memory > 'maxmemory'.\r\n"},
+		{executor.AllKeysLRU, "+OK\r\n"},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			srv := New(Config{Addr: "127.0.0.1:0", MaxMemory: 1000, MaxMemoryPolicy: tc.policy})
+			require.NoError(t, srv.Start(t.Context()))
+			t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+			conn := connect(t, srv)
+
+			// Each key takes about 200 bytes, so five of them are over the
+			// limit.
+			val := strings.Repeat("x", 128)
+			for i := range 5 {
+				exchange(t, conn, "+OK\r\n", command("SET", fmt.Sprint("key:", i), val))
+			}
+			exchange(t, conn, tc.want, command("SET", "last", val))
+			exchange(t, conn, "$1\r\nx\r\n", command("ECHO", "x"))
+		})
+	}
+}
+
+func TestObjectAndMemory(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+
+	exchange(t, conn, "+OK\r\n:2\r\n", command("SET", "n", "42"), command("RPUSH", "l", "a", "b"))
+	exchange(t, conn, "$3\r\nint\r\n$8\r\nlistpack\r\n$-1\r\n",
+		command("OBJECT", "ENCODING", "n"), command("OBJECT", "ENCODING", "l"), command("OBJECT", "ENCODING", "missing"))
+	exchange(t, conn, ":0\r\n", command("OBJECT", "IDLETIME", "n"))
+	exchange(t, conn, "$-1\r\n-ERR syntax error\r\n",
+		command("MEMORY", "USAGE", "missing"), command("MEMORY", "USAGE", "n", "FOO", "1"))
+}
+
+func TestInfo(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+
+	exchange(t, conn, "+OK\r\n+OK\r\n+OK\r\n", command("SET", "a", "1"), command("SELECT", "2"), command("SET", "b", "1"))
+
+	keyspace := "# Keyspace\r\ndb0:keys=1,expires=0,avg_ttl=0\r\ndb2:keys=1,expires=0,avg_ttl=0\r\n"
+	exchange(t, conn, fmt.Sprintf("$%d\r\n%s\r\n", len(keyspace), keyspace), command("INFO", "keyspace"))
+
+	clients := "# Clients\r\nconnected_clients:1\r\nblocked_clients:0\r\npubsub_clients:0\r\n"
+	exchange(t, conn, fmt.Sprintf("$%d\r\n%s\r\n", len(clients), clients), command("INFO", "CLIENTS"))
+	exchange(t, conn, "$0\r\n\r\n", command("INFO", "unknown"))
+}
+
+func TestSlowLog(t *testing.T) {
+	srv := New(Config{Addr: "127.0.0.1:0", SlowLogSlowerThan: time.Nanosecond, SlowLogMaxLen: 2})
+	require.NoError(t, srv.Start(t.Context()))
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+	conn := connect(t, srv)
+
+	exchange(t, conn, "+OK\r\n+OK\r\n:2\r\n",
+		command("SET", "a", "1"), command("SET", "b", strings.Repeat("x", 130)), command("SLOWLOG", "LEN"))
+
+	// The durations vary, so the reply is checked line by line.
+	_, err := conn.Write(command("SLOWLOG", "GET", "2"))
+	require.NoError(t, err)
+	r := bufio.NewReader(conn)
+	var lines []string
+	for range 29 {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimSuffix(line, "\r\n"))
+	}
+	assert.Equal(t, []string{"*2", "*6", ":3"}, lines[:3], "the newest entry is SLOWLOG LEN")
+	assert.Equal(t, []string{"*2", "$7", "SLOWLOG", "$3", "LEN"}, lines[5:10])
+	assert.Equal(t, []string{"$0", ""}, lines[12:14], "no client name")
+	assert.Equal(t, []string{"*6", ":2"}, lines[14:16], "the first SET was dropped")
+	assert.Equal(t, strings.Repeat("x", 128)+"... (2 more bytes)", lines[24])
+
+	exchange(t, conn, "+OK\r\n:1\r\n", command("SLOWLOG", "RESET"), command("SLOWLOG", "LEN"))
+	exchange(t, conn, "*0\r\n", command("LATENCY", "LATEST"))
+}
+
+func TestLatencyDoctor(t *testing.T) {
+	doctor := func(conn net.Conn, r *bufio.Reader) string {
+		t.Helper()
+
+		_, err := conn.Write(command("LATENCY", "DOCTOR"))
+		require.NoError(t, err)
+		return readBulk(t, r)
+	}
+
+	conn := connect(t, start(t, protocol.DefaultLimits))
+	assert.Contains(t, doctor(conn, bufio.NewReader(conn)), "Latency monitoring is disabled")
+
+	srv := New(Config{Addr: "127.0.0.1:0", LatencyMonitorThreshold: time.Nanosecond})
+	require.NoError(t, srv.Start(t.Context()))
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+	conn = connect(t, srv)
+	r := bufio.NewReader(conn)
+
+	assert.Contains(t, doctor(conn, r), "no latency spike was observed")
+	report := doctor(conn, r)
+	assert.Contains(t, report, "1. command: 1 latency spikes")
+	assert.Contains(t, report, "- Check your Slow Log")
+}
+
+// stall pipelines n requests for a 1MB value on conn without reading the
+// replies, which soon fill up the socket buffers.
+func stall(t *testing.T, conn net.Conn, n int) {
+	t.Helper()
+
+	exchange(t, conn, "+OK\r\n", command("SET", "big", strings.Repeat("x", 1<<20)))
+	send(conn, bytes.Repeat(command("GET", "big"), n))
+}
+
+func TestStalledClientDoesNotBlockOthers(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	stall(t, connect(t, srv), 64)
+
+	conn := connect(t, srv)
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	exchange(t, conn, "+PONG\r\n", command("PING"))
+}
+
+func TestOutputLimitClosesClient(t *testing.T) {
+	srv := New(Config{Addr: "127.0.0.1:0", NormalOutputLimit: executor.OutputBufferLimit{Hard: 4 << 20}})
+	require.NoError(t, srv.Start(t.Context()))
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+	stall(t, connect(t, srv), 64)
+
+	conn := connect(t, srv)
+	clients := "# Clients\r\nconnected_clients:1\r\nblocked_clients:0\r\npubsub_clients:0\r\n"
+	want := fmt.Sprintf("$%d\r\n%s\r\n", len(clients), clients)
+	assert.Eventually(t, func() bool {
+		_, err := conn.Write(command("INFO", "clients"))
+		require.NoError(t, err)
+		got := make([]byte, len(want))
+		_, err = io.ReadFull(conn, got)
+		require.NoError(t, err)
+		return string(got) == want
+	}, 5*time.Second, 10*time.Millisecond, "the stalled client is disconnected")
+}
+
+func TestPubSub(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	sub, pub := connect(t, srv), connect(t, srv)
+
+	exchange(t, sub, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n"+
+		"*3\r\n$10\r\npsubscribe\r\n$2\r\nn*\r\n:2\r\n",
+		command("SUBSCRIBE", "news"), command("PSUBSCRIBE", "n*"))
+
+	exchange(t, pub, "*1\r\n$4\r\nnews\r\n*4\r\n$4\r\nnews\r\n:1\r\n$5\r\nother\r\n:0\r\n:1\r\n",
+		command("PUBSUB", "CHANNELS"), command("PUBSUB", "NUMSUB", "news", "other"), command("PUBSUB", "NUMPAT"))
+
+	exchange(t, pub, ":2\r\n:1\r\n", command("PUBLISH", "news", "hi"), command("PUBLISH", "nope", "ho"))
+	exchange(t, sub, "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$2\r\nhi\r\n"+
+		"*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$2\r\nhi\r\n"+
+		"*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnope\r\n$2\r\nho\r\n")
+
+	// Only the subscription commands, PING, QUIT and RESET run in
+	// subscribed mode.
+	exchange(t, sub, "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n"+
+		"*2\r\n$4\r\npong\r\n$0\r\n\r\n",
+		command("GET", "k"), command("PING"))
+
+	exchange(t, sub, "*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:1\r\n"+
+		"*3\r\n$12\r\npunsubscribe\r\n$2\r\nn*\r\n:0\r\n$-1\r\n",
+		command("UNSUBSCRIBE"), command("PUNSUBSCRIBE"), command("GET", "k"))
+
+	exchange(t, pub, "*0\r\n:0\r\n", command("PUBSUB", "CHANNELS"), command("PUBSUB", "NUMPAT"))
+}
+
+func TestResetAndQuit(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn, other := connect(t, srv), connect(t, srv)
+
+	// RESET runs inside MULTI rather than being queued.
+	exchange(t, conn, "+OK\r\n+OK\r\n+OK\r\n+OK\r\n+OK\r\n+RESET\r\n$-1\r\n$-1\r\n-ERR EXEC without MULTI\r\n",
+		command("SELECT", "1"), command("SET", "k", "v"), command("CLIENT", "SETNAME", "x"),
+		command("WATCH", "k"), command("MULTI"), command("RESET"),
+		command("GET", "k"), command("CLIENT", "GETNAME"), command("EXEC"))
+
+	exchange(t, conn, "*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n+RESET\r\n$-1\r\n",
+		command("SUBSCRIBE", "ch"), command("RESET"), command("GET", "k"))
+	exchange(t, other, "*2\r\n$2\r\nch\r\n:0\r\n", command("PUBSUB", "NUMSUB", "ch"))
+
+	for _, conn := range []net.Conn{conn, other} {
+		exchange(t, conn, "*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n+OK\r\n", command("SUBSCRIBE", "ch"), command("QUIT"))
+		rest, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		assert.Empty(t, rest, "QUIT closes the connection")
+	}
+
+	conn = connect(t, srv)
+	exchange(t, conn, "+OK\r\n+OK\r\n", command("MULTI"), command("QUIT"))
+	_, err := io.ReadAll(conn)
+	require.NoError(t, err)
+}
+
+func TestShardedPubSub(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	sub, pub := connect(t, srv), connect(t, srv)
+
+	// Shard subscriptions are counted apart from the others.
+	exchange(t, sub, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n"+
+		"*3\r\n$10\r\nssubscribe\r\n$5\r\nshard\r\n:1\r\n",
+		command("SUBSCRIBE", "news"), command("SSUBSCRIBE", "shard"))
+
+	exchange(t, pub, "*1\r\n$5\r\nshard\r\n*2\r\n$5\r\nshard\r\n:1\r\n*1\r\n$4\r\nnews\r\n",
+		command("PUBSUB", "SHARDCHANNELS"), command("PUBSUB", "SHARDNUMSUB", "shard"), command("PUBSUB", "CHANNELS"))
+
+	// Shard channels are a namespace of their own.
+	exchange(t, pub, ":0\r\n:0\r\n:1\r\n", command("PUBLISH", "shard", "lost"),
+		command("SPUBLISH", "news", "lost"), command("SPUBLISH", "shard", "hey"))
+	exchange(t, sub, "*3\r\n$8\r\nsmessage\r\n$5\r\nshard\r\n$3\r\nhey\r\n")
+
+	exchange(t, sub, "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n"+
+		"*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:0\r\n"+
+		"-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n"+
+		"*3\r\n$12\r\nsunsubscribe\r\n$5\r\nshard\r\n:0\r\n$-1\r\n",
+		command("GET", "k"), command("UNSUBSCRIBE"), command("GET", "k"), command("SUNSUBSCRIBE"), command("GET", "k"))
+
+	exchange(t, pub, "*0\r\n", command("PUBSUB", "SHARDCHANNELS"))
+}
+
+func TestHello(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+
+	r := bufio.NewReader(conn)
+	_, err := conn.Write(command("CLIENT", "ID"))
+	require.NoError(t, err)
+	id, err := r.ReadString('\n')
+	require.NoError(t, err)
+
+	exchange(t, conn, "-NOPROTO unsupported protocol version\r\n$-1\r\n", command("HELLO", "4"), command("GET", "k"))
+
+	// An empty string is a zero length bulk string, not a null, in either
+	// protocol.
+	exchange(t, conn, "+OK\r\n$0\r\n\r\n$-1\r\n",
+		command("SET", "e", ""), command("GET", "e"), command("CLIENT", "GETNAME"))
+
+	exchange(t, conn, "%7\r\n$6\r\nserver\r\n$5\r\nredis\r\n$7\r\nversion\r\n$5\r\n7.4.0\r\n"+
+		"$5\r\nproto\r\n:3\r\n$2\r\nid\r\n"+id+"$4\r\nmode\r\n$10\r\nstandalone\r\n"+
+		"$4\r\nrole\r\n$6\r\nmaster\r\n$7\r\nmodules\r\n*0\r\n",
+		command("HELLO", "3"))
+
+	// Replies use the RESP3 types from now on.
+	exchange(t, conn, "_\r\n$0\r\n\r\n_\r\n_\r\n_\r\n:1\r\n*1\r\n*2\r\n,13.361389338970184\r\n,38.1155563954963\r\n",
+		command("GET", "k"), command("GET", "e"), command("CLIENT", "GETNAME"), command("LPOP", "k"), command("BLPOP", "k", "0.01"),
+		command("GEOADD", "g", "13.361389", "38.115556", "a"), command("GEOPOS", "g", "a"))
+	exchange(t, conn, "$3\r\n1-1\r\n%1\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n",
+		command("XADD", "s", "1-1", "f", "v"), command("XREAD", "STREAMS", "s", "0"))
+
+	_, err = conn.Write(command("CLIENT", "INFO"))
+	require.NoError(t, err)
+	header, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, byte('='), header[0], header)
+	n, err := strconv.Atoi(strings.TrimSuffix(header[1:], "\r\n"))
+	require.NoError(t, err)
+	body := make([]byte, n+2)
+	_, err = io.ReadFull(r, body)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "txt:id="+strings.TrimSuffix(id[1:], "\r\n")+" "), string(body))
+	assert.Contains(t, string(body), " resp=3\n")
+
+	// Messages arrive as pushes, and subscribed clients may run any command.
+	exchange(t, conn, ">3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n_\r\n", command("SUBSCRIBE", "ch"), command("GET", "k"))
+	exchange(t, connect(t, srv), ":1\r\n", command("PUBLISH", "ch", "hi"))
+	exchange(t, conn, ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n")
+	exchange(t, conn, ">3\r\n$11\r\nunsubscribe\r\n$2\r\nch\r\n:0\r\n", command("UNSUBSCRIBE"))
+	exchange(t, conn, ">3\r\n$11\r\nunsubscribe\r\n_\r\n:0\r\n", command("UNSUBSCRIBE"))
+
+	exchange(t, conn, "*14\r\n$6\r\nserver\r\n$5\r\nredis\r\n$7\r\nversion\r\n$5\r\n7.4.0\r\n"+
+		"$5\r\nproto\r\n:2\r\n$2\r\nid\r\n"+id+"$4\r\nmode\r\n$10\r\nstandalone\r\n"+
+		"$4\r\nrole\r\n$6\r\nmaster\r\n$7\r\nmodules\r\n*0\r\n$-1\r\n",
+		command("HELLO", "2"), command("GET", "k"))
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	srv := New(Config{Addr: "127.0.0.1:0", PubSubOutputLimit: executor.OutputBufferLimit{Hard: 4 << 20}})
+	require.NoError(t, srv.Start(t.Context()))
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	sub := connect(t, srv)
+	exchange(t, sub, "*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n", command("SUBSCRIBE", "ch"))
+
+	// The subscriber reads nothing more, yet the publisher is not held up.
+	pub := connect(t, srv)
+	require.NoError(t, pub.SetDeadline(time.Now().Add(5*time.Second)))
+	msg := strings.Repeat("x", 1<<20)
+	r := bufio.NewReader(pub)
+	var last string
+	for range 64 {
+		_, err := pub.Write(command("PUBLISH", "ch", msg))
+		require.NoError(t, err)
+		last, err = r.ReadString('\n')
+		require.NoError(t, err)
+	}
+	assert.Equal(t, ":0\r\n", last, "the subscriber is gone")
+}
+
+func TestExpiryFollowsMovedKeys(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+
+	exchange(t, conn, "+OK\r\n:1\r\n+OK\r\n$1\r\nv\r\n",
+		command("SET", "k", "v", "PX", "100"), command("MOVE", "k", "1"), command("SWAPDB", "0", "1"), command("GET", "k"))
+
+	r := bufio.NewReader(conn)
+	assert.Eventually(t, func() bool {
+		_, err := conn.Write(command("INFO", "keyspace"))
+		require.NoError(t, err)
+		header, err := r.ReadString('\n')
+		require.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSuffix(header[1:], "\r\n"))
+		require.NoError(t, err)
+		body := make([]byte, n+2)
+		_, err = io.ReadFull(r, body)
+		require.NoError(t, err)
+		return string(body) == "# Keyspace\r\n\r\n"
+	}, 5*time.Second, 50*time.Millisecond, "the key expires in the database it was moved to")
+	exchange(t, conn, "$-1\r\n", command("GET", "k"))
+}
+
+func TestGeo(t *testing.T) {
+	conn := dial(t)
+
+	exchange(t, conn, "-ERR XX and NX options at the same time are not compatible\r\n:2\r\n",
+		command("GEOADD", "Sicily", "NX", "XX", "13.361389", "38.115556", "Palermo"),
+		command("GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"))
+	exchange(t, conn, "*1\r\n*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n",
+		command("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "100", "km", "WITHDIST"))
+}
+
+// readBulk reads a bulk string reply.
+func readBulk(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	header, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, byte('$'), header[0], header)
+	n, err := strconv.Atoi(strings.TrimSuffix(header[1:], "\r\n"))
+	require.NoError(t, err)
+
+	body := make([]byte, n+2)
+	_, err = io.ReadFull(r, body)
+	require.NoError(t, err)
+	return string(body[:n])
+}
+
+func TestClientListAndKill(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	a, b, c := connect(t, srv), connect(t, srv), connect(t, srv)
+
+	exchange(t, b, "+OK\r\n", command("CLIENT", "SETNAME", "victim"))
+	_, err := b.Write(command("CLIENT", "ID"))
+	require.NoError(t, err)
+	id, err := bufio.NewReader(b).ReadString('\n')
+	require.NoError(t, err)
+	id = strings.TrimSuffix(id[1:], "\r\n")
+
+	r := bufio.NewReader(a)
+	_, err = a.Write(command("CLIENT", "LIST", "ID", id))
+	require.NoError(t, err)
+	list := readBulk(t, r)
+	assert.Contains(t, list, "id="+id+" addr="+b.LocalAddr().String())
+	assert.Contains(t, list, " name=victim ")
+	assert.Equal(t, 1, strings.Count(list, "\n"))
+
+	exchange(t, a, ":1\r\n:0\r\n", command("CLIENT", "KILL", "ID", id), command("CLIENT", "KILL", "ID", id))
+	_, err = io.ReadAll(b)
+	require.NoError(t, err, "the killed connection is closed")
+
+	exchange(t, a, "+OK\r\n-ERR No such client\r\n",
+		command("CLIENT", "KILL", c.LocalAddr().String()), command("CLIENT", "KILL", c.LocalAddr().String()))
+	_, err = io.ReadAll(c)
+	require.NoError(t, err)
+
+	_, err = a.Write(command("CLIENT", "LIST"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(readBulk(t, r), "\n"), "only the killer is left")
+}
+
+func TestClientPause(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	a, b := connect(t, srv), connect(t, srv)
+
+	exchange(t, a, "+OK\r\n", command("CLIENT", "PAUSE", "10000", "WRITE"))
+	exchange(t, b, "$-1\r\n", command("GET", "k"))
+
+	// Writes wait for the pause to end.
+	_, err := b.Write(command("SET", "k", "v"))
+	require.NoError(t, err)
+	require.NoError(t, b.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err = b.Read(make([]byte, 1))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	require.NoError(t, b.SetReadDeadline(time.Now().Add(5*time.Second)))
+	exchange(t, a, "+OK\r\n", command("CLIENT", "UNPAUSE"))
+	exchange(t, b, "+OK\r\n")
+	exchange(t, a, "$1\r\nv\r\n", command("GET", "k"))
+}
+
+func TestClientNoEvict(t *testing.T) {
+	srv := New(Config{Addr: "127.0.0.1:0", MaxMemoryClients: 24 << 20})
+	require.NoError(t, srv.Start(t.Context()))
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	spared, evicted := connect(t, srv), connect(t, srv)
+	exchange(t, spared, "+OK\r\n", command("CLIENT", "NO-EVICT", "ON"))
+	stall(t, spared, 8)
+	stall(t, evicted, 64)
+
+	conn := connect(t, srv)
+	r := bufio.NewReader(conn)
+	assert.Eventually(t, func() bool {
+		_, err := conn.Write(command("CLIENT", "LIST"))
+		require.NoError(t, err)
+		list := readBulk(t, r)
+		return strings.Count(list, "\n") == 2 && strings.Contains(list, "flags=e")
+	}, 5*time.Second, 10*time.Millisecond, "only the client holding the most replies is evicted")
+}
+
+func TestHelp(t *testing.T) {
+	srv := start(t, protocol.DefaultLimits)
+	conn := connect(t, srv)
+	r := bufio.NewReader(conn)
+
+	for _, name := range []string{"CLIENT", "OBJECT", "MEMORY", "LATENCY", "SLOWLOG", "XGROUP", "XINFO", "PUBSUB", "COMMAND"} {
+		_, err := conn.Write(command(name, "HELP"))
+		require.NoError(t, err)
+
+		header, err := r.ReadString('\n')
+		require.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "*"), "\r\n"))
+		require.NoError(t, err, header)
+
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i], err = r.ReadString('\n')
+			require.NoError(t, err)
+		}
+		assert.Equal(t, "+"+name+" <subcommand> [<arg> [value] [opt] ...]. Subcommands are:\r\n", lines[0])
+		assert.Equal(t, []string{"+HELP\r\n", "+    Print this help.\r\n"}, lines[n-2:])
+	}
+}